@@ -1,12 +1,27 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	_ "traductio/internal/auth/basic"
+	_ "traductio/internal/auth/bearer"
+	_ "traductio/internal/auth/mtls"
+	_ "traductio/internal/auth/oauth2"
+	_ "traductio/internal/auth/sigv4"
 	"traductio/internal/inputreader"
 	"traductio/internal/sink"
+	_ "traductio/internal/sink/file"
+	_ "traductio/internal/sink/graphite"
 	_ "traductio/internal/sink/influx"
+	_ "traductio/internal/sink/influx1"
+	_ "traductio/internal/sink/otlp"
+	_ "traductio/internal/sink/parquet"
+	_ "traductio/internal/sink/postgres"
+	_ "traductio/internal/sink/remotewrite"
+	_ "traductio/internal/sink/sqlite"
 	_ "traductio/internal/sink/timestream"
 
 	"github.com/spf13/cobra"
@@ -86,7 +101,7 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 
 	if a.cfg.run.stopAfter == StepReadConfig.String() {
 		info("Printing configuration file as read to STDOUT and exiting...")
-		fmt.Println(c)
+		fmt.Println(inputreader.Redact(c.String()))
 		return
 	}
 
@@ -96,27 +111,55 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 
 	i, err := inputreader.NewInput(c.Input, vars)
 	exitOnErr(err)
+	i.Info = info
+
+	err = c.Render(vars)
+	exitOnErr(err)
+
+	validators, errs := c.Validators.Compile()
+	iterator, iteratorErrs := c.Process.Iterator.Compile()
+	errs = append(errs, iteratorErrs...)
+	if c.Input.Stream {
+		_, err := c.Process.Iterator.StreamPath()
+		errs = append(errs, err)
+	}
+	exitOnErr(errs...)
 
 	if a.cfg.run.stopAfter == StepPreFetch.String() {
 		info("Printing rendered input data to STDOUT and exiting...")
 		b, _ := yaml.Marshal(i)
-		fmt.Println(string(b))
+		fmt.Println(inputreader.Redact(string(b)))
+		return
+	}
+
+	if c.Input.Stream {
+		a.stream(c, i, validators, iterator)
 		return
 	}
 
 	// STEP Fetch
-	data, err := i.Fetch()
+	pages, err := i.FetchPages()
 	exitOnErr(err)
 
 	if a.cfg.run.stopAfter == StepFetch.String() {
 		info("Printing fetched data to STDOUT and exiting...")
-		fmt.Println(string(data))
+		for _, data := range pages {
+			fmt.Println(string(data))
+		}
 		return
 	}
 
 	// STEP Validate
-	_, errs := c.Validators.ValidateContent(data)
-	exitOnErr(errs...)
+	docs := []interface{}{}
+	for _, data := range pages {
+		var doc interface{}
+		err := json.Unmarshal(data, &doc)
+		exitOnErr(err)
+
+		_, errs := validators.Validate(doc)
+		exitOnErr(errs...)
+		docs = append(docs, doc)
+	}
 
 	if a.cfg.run.stopAfter == StepValidate.String() {
 		info("Validation was successful, exiting...")
@@ -124,45 +167,129 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 	}
 
 	// STEP Process
-	//points, _, fragment, err := Process(data, c.Process.Iterator, sink.Point{}, true)
-	points, _, _, err := Process(data, c.Process.Iterator, sink.Point{}, false)
-	exitOnErr(err)
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	points := []sink.Point{}
+	for _, doc := range docs {
+		//processed, _, fragment, err := iterator.Process(doc, sink.Point{}, true)
+		processed, _, _, err := iterator.Process(doc, sink.Point{}, false)
+		exitOnErr(err)
+		points = append(points, processed...)
 	}
 
 	if a.cfg.run.stopAfter == StepProcess.String() {
 		info("Printing extracted points to STDOUT and last iterator fragment to STDERR and exiting...")
-
-		if !c.Process.NoTrim {
-			points, _, _ = sink.TrimPoints(points)
-		}
-		table, err := sink.PointsAsCSV(points, ",")
-		exitOnErr(err)
-
-		fmt.Println(string(table))
-
+		printPoints(c, points)
 		//info(fragment)
 		return
 	}
 	// STEP Store
-	fmt.Println("Going to create sink")
-	t, err := sink.New(c.Output)
+	fmt.Println("Going to create sinks")
+	t, err := sink.NewFanOut(c.Sinks())
 	exitOnErr(err)
+	defer t.Close()
 
 	if len(points) < 1 {
 		fmt.Println("No data points to save")
-		os.Exit(0)
+		return
 	}
 
-	fmt.Printf("Saving %d data points to sink\n", len(points))
+	err = t.Prepare(c.Process.Iterator.GetStructure())
+	exitOnErr(err)
+
+	fmt.Printf("Saving %d data points to %d sinks\n", len(points), len(c.Sinks()))
 	err = t.Write(points)
+	for _, r := range t.Results() {
+		info(r.String())
+	}
+	exitOnErr(err)
+	fmt.Println("Data points saved")
+}
+
+// stream performs the steps following PreFetch without reading the input
+// as a whole. The input is validated up to the elements selected by the
+// outermost iterator, the points are saved in batches while the elements
+// are read.
+func (a *App) stream(c Config, i inputreader.Input, validators compiledValidators, iterator *compiledIterator) {
+	// STEP Fetch
+	r, err := i.Fetch()
+	exitOnErr(err)
+	defer r.Close()
+
+	if a.cfg.run.stopAfter == StepFetch.String() {
+		info("Printing fetched data to STDOUT and exiting...")
+		_, err := io.Copy(os.Stdout, r)
+		exitOnErr(err)
+		fmt.Println()
+		return
+	}
+
+	// STEP Validate
+	errValidated := fmt.Errorf("validated")
+	validate := func(doc interface{}) error {
+		_, errs := validators.Validate(doc)
+		exitOnErr(errs...)
+		if a.cfg.run.stopAfter == StepValidate.String() {
+			return errValidated
+		}
+		return nil
+	}
+
+	// STEP Process
+	if a.cfg.run.stopAfter == StepValidate.String() || a.cfg.run.stopAfter == StepProcess.String() {
+		points := []sink.Point{}
+		err := iterator.Stream(r, validate, func(batch []sink.Point) error {
+			points = append(points, batch...)
+			return nil
+		}, c.Process.BatchSize)
+		if err == errValidated {
+			info("Validation was successful, exiting...")
+			return
+		}
+		exitOnErr(err)
+
+		info("Printing extracted points to STDOUT and exiting...")
+		printPoints(c, points)
+		return
+	}
+
+	// STEP Store
+	fmt.Println("Going to create sinks")
+	t, err := sink.NewFanOut(c.Sinks())
+	exitOnErr(err)
 	defer t.Close()
+
+	err = t.Prepare(c.Process.Iterator.GetStructure())
 	exitOnErr(err)
+
+	fmt.Printf("Saving data points to %d sinks while reading the input\n", len(c.Sinks()))
+	saved := 0
+	err = iterator.Stream(r, validate, func(batch []sink.Point) error {
+		saved += len(batch)
+		info(fmt.Sprintf("Saving data points %d to %d", saved-len(batch)+1, saved))
+		return t.Write(batch)
+	}, c.Process.BatchSize)
+	for _, r := range t.Results() {
+		info(r.String())
+	}
+	exitOnErr(err)
+
+	if saved < 1 {
+		fmt.Println("No data points to save")
+		return
+	}
 	fmt.Println("Data points saved")
 }
 
+// printPoints prints the points as CSV to STDOUT.
+func printPoints(c Config, points []sink.Point) {
+	if !c.Process.NoTrim {
+		points, _, _ = sink.TrimPoints(points)
+	}
+	table, err := sink.PointsAsCSV(points, ",")
+	exitOnErr(err)
+
+	fmt.Println(string(table))
+}
+
 func (a *App) versionCmd(cmd *cobra.Command, args []string) {
 	fmt.Println(versionInfo())
 }
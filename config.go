@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"strconv"
 	"traductio/internal/inputreader"
 	"traductio/internal/sink"
 
+	"github.com/itchyny/gojq"
 	"gopkg.in/yaml.v2"
 )
 
@@ -12,16 +16,69 @@ type Config struct {
 	Input      inputreader.InputConfig `yaml:"input"`
 	Validators Validators              `yaml:"validators"`
 	Process    ProcessConfig           `yaml:"process"`
-	Output     sink.Config             `yaml:"output"`
+	Output     sink.Config             `yaml:"output,omitempty"`
+	Outputs    []sink.Config           `yaml:"outputs,omitempty"`
+}
+
+// Sinks returns the configurations of all outputs, e.g. the one of the
+// 'output' section followed by the ones listed in the 'outputs' section.
+func (c Config) Sinks() []sink.Config {
+	sinks := []sink.Config{}
+	if c.Output.Kind != "" {
+		sinks = append(sinks, c.Output)
+	}
+	return append(sinks, c.Outputs...)
 }
 
 type Validators []Validator
 
+// ValidateContent validates the JSON document data. Use Validators.Compile
+// and compiledValidators.Validate to validate more than one document.
 func (v Validators) ValidateContent(data []byte) (bool, []error) {
+	c, errs := v.Compile()
+	if len(errs) > 0 {
+		return false, errs
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false, []error{err}
+	}
+	return c.Validate(doc)
+}
+
+// Compile compiles the selectors of all validators. Every invalid selector
+// is reported with its location in the configuration.
+func (v Validators) Compile() (compiledValidators, []error) {
+	compiled := compiledValidators{}
+	errs := []error{}
+	for n, check := range v {
+		query, err := compileQuery(fmt.Sprintf("validators[%d].selector", n), check.Selector)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		compiled = append(compiled, compiledValidator{Validator: check, query: query})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return compiled, nil
+}
+
+type compiledValidators []compiledValidator
+
+type compiledValidator struct {
+	Validator
+	query *gojq.Code
+}
+
+// Validate validates the decoded JSON document doc.
+func (v compiledValidators) Validate(doc interface{}) (bool, []error) {
 	errs := []error{}
 	ok := true
 	for _, check := range v {
-		v, err := queryBytes(data, check.Selector)
+		v, err := queryBytes(doc, check.query)
 		if err != nil {
 			ok = false
 			errs = append(errs, err)
@@ -30,7 +87,7 @@ func (v Validators) ValidateContent(data []byte) (bool, []error) {
 		value := string(v)
 		if value != check.Expect {
 			ok = false
-			err = fmt.Errorf("value at '%s' is expected to be '%s', is '%s'", check.Selector, value, check.Expect)
+			err = fmt.Errorf("value at '%s' is expected to be '%s', is '%s'", check.Selector, check.Expect, value)
 			errs = append(errs, err)
 		}
 	}
@@ -45,16 +102,19 @@ type Validator struct {
 type ProcessConfig struct {
 	Iterator Iterator `yaml:"iterator"`
 	NoTrim   bool     `yaml:"no_trim"`
+	// BatchSize is the number of points saved at once when streaming the
+	// input
+	BatchSize int `yaml:"batch_size"`
 }
 
 type Iterator struct {
-	Selector    string             `yaml:"selector"`
-	Time        TimeSet            `yaml:"time"`
-	Tags        map[string]string  `yaml:"tags"`
-	FixedTags   map[string]string  `yaml:"fixed_tags"`
-	Values      map[string]string  `yaml:"values"`
-	FixedValues map[string]float64 `yaml:"fixed_values"`
-	Iterator    *Iterator          `yaml:"iterator"`
+	Selector    string            `yaml:"selector"`
+	Time        TimeSet           `yaml:"time"`
+	Tags        map[string]string `yaml:"tags"`
+	FixedTags   map[string]string `yaml:"fixed_tags"`
+	Values      map[string]string `yaml:"values"`
+	FixedValues map[string]string `yaml:"fixed_values"`
+	Iterator    *Iterator         `yaml:"iterator"`
 }
 
 type TimeSet struct {
@@ -83,6 +143,91 @@ func (i Iterator) GetStructure() (tags []string, values []string) {
 	return
 }
 
+// Render renders the templated portions of the configuration, e.g. the
+// output section as well as the fixed tags and fixed values of the process
+// section, using the vars passed.
+func (c *Config) Render(vars map[string]string) error {
+	var err error
+
+	c.Output, err = renderSinkConfig(c.Output, vars)
+	if err != nil {
+		return err
+	}
+
+	for i := range c.Outputs {
+		c.Outputs[i], err = renderSinkConfig(c.Outputs[i], vars)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Process.Iterator, err = c.Process.Iterator.Render(vars)
+	return err
+}
+
+func renderSinkConfig(c sink.Config, vars map[string]string) (sink.Config, error) {
+	var err error
+	out := sink.Config{
+		Name:       c.Name,
+		Policy:     c.Policy,
+		Connection: map[string]string{},
+	}
+
+	out.Kind, err = inputreader.RenderTemplate(c.Kind, "output kind", vars)
+	if err != nil {
+		return out, err
+	}
+
+	for k, v := range c.Connection {
+		out.Connection[k], err = inputreader.RenderTemplate(v, fmt.Sprintf("output connection '%s'", k), vars)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+// Render returns a copy of the iterator with its fixed tags and fixed values
+// as well as the ones of its nested iterators rendered using the vars passed.
+func (i Iterator) Render(vars map[string]string) (Iterator, error) {
+	var err error
+	out := i
+
+	if i.FixedTags != nil {
+		out.FixedTags = map[string]string{}
+	}
+	for k, v := range i.FixedTags {
+		out.FixedTags[k], err = inputreader.RenderTemplate(v, fmt.Sprintf("fixed tag '%s'", k), vars)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	if i.FixedValues != nil {
+		out.FixedValues = map[string]string{}
+	}
+	for k, v := range i.FixedValues {
+		out.FixedValues[k], err = inputreader.RenderTemplate(v, fmt.Sprintf("fixed value '%s'", k), vars)
+		if err != nil {
+			return out, err
+		}
+		if _, err = strconv.ParseFloat(out.FixedValues[k], 64); err != nil {
+			return out, fmt.Errorf("fixed value '%s' is not a number: %s", k, out.FixedValues[k])
+		}
+	}
+
+	if i.Iterator != nil {
+		nested, err := i.Iterator.Render(vars)
+		if err != nil {
+			return out, err
+		}
+		out.Iterator = &nested
+	}
+
+	return out, nil
+}
+
 func ReadConfig(cfgFile string) (Config, error) {
 	c := Config{}
 
@@ -91,10 +236,16 @@ func ReadConfig(cfgFile string) (Config, error) {
 		return c, err
 	}
 
-	data, err := i.Fetch()
+	r, err := i.Fetch()
 	if err != nil {
 		return c, err
 	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return c, fmt.Errorf("error while reading %s: %s", cfgFile, err.Error())
+	}
 
 	err = yaml.Unmarshal(data, &c)
 	if err != nil {
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"traductio/internal/sink"
+)
+
+func TestConfigRender(t *testing.T) {
+	vars := map[string]string{"env": "prod", "tenant": "acme", "factor": "1.5"}
+	c := Config{
+		Process: ProcessConfig{
+			Iterator: Iterator{
+				Selector:  ".items[]",
+				FixedTags: map[string]string{"env": "{{.env}}"},
+				Iterator: &Iterator{
+					Selector:    ".values[]",
+					FixedValues: map[string]string{"factor": "{{.factor}}"},
+				},
+			},
+		},
+		Output: sink.Config{
+			Kind: "influx",
+			Connection: map[string]string{
+				"bucket": "{{.env}}",
+				"series": "requests_{{.tenant}}",
+			},
+		},
+	}
+
+	err := c.Render(vars)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	expected := map[string]string{"bucket": "prod", "series": "requests_acme"}
+	if !reflect.DeepEqual(c.Output.Connection, expected) {
+		t.Errorf("output connection is not as expected: %v", c.Output.Connection)
+	}
+	if c.Process.Iterator.FixedTags["env"] != "prod" {
+		t.Errorf("fixed tag is not as expected: %v", c.Process.Iterator.FixedTags)
+	}
+	if c.Process.Iterator.Iterator.FixedValues["factor"] != "1.5" {
+		t.Errorf("fixed value of nested iterator is not as expected: %v", c.Process.Iterator.Iterator.FixedValues)
+	}
+
+	c.Process.Iterator.FixedValues = map[string]string{"broken": "{{.env}}"}
+	err = c.Render(vars)
+	if err == nil {
+		t.Errorf("error was expected for a fixed value which is not a number, error was <nil>")
+	}
+}
+
+func TestConfigCompile(t *testing.T) {
+	c := Config{
+		Validators: Validators{
+			{Selector: "._shards.failed", Expect: "0"},
+			{Selector: "._shards.failed |", Expect: "0"},
+			{Selector: "._shards.failed | foo bar", Expect: "0"},
+		},
+		Process: ProcessConfig{
+			Iterator: Iterator{
+				Selector: ".items[]",
+				Time:     TimeSet{Selector: ".time[", Format: "unixTimestamp"},
+				Iterator: &Iterator{
+					Selector: ".values[]",
+					Tags:     map[string]string{"name": ".name"},
+					Values:   map[string]string{"bytes_sent": ".bytes_sent.value)", "count": "undefined_function(1)"},
+				},
+			},
+		},
+	}
+
+	_, errs := c.Validators.Compile()
+	expected := []string{
+		"invalid selector '._shards.failed |' at validators[1].selector: unexpected token <EOF> at position 18",
+		"invalid selector '._shards.failed | foo bar' at validators[2].selector: unexpected token \"bar\" at position 23",
+	}
+	if got := errorStrings(errs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("validator errors are not as expected: %q", got)
+	}
+
+	_, errs = c.Process.Iterator.Compile()
+	expected = []string{
+		"invalid selector '.time[' at process.iterator.time.selector: unexpected token <EOF> at position 7",
+		"invalid selector '.bytes_sent.value)' at process.iterator.iterator.values.bytes_sent: unexpected token \")\" at position 18",
+		"invalid selector 'undefined_function(1)' at process.iterator.iterator.values.count: function not defined: undefined_function/1",
+	}
+	if got := errorStrings(errs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("iterator errors are not as expected: %q", got)
+	}
+
+	if _, _, _, err := Process([]byte(`{}`), c.Process.Iterator, sink.Point{}, false); err == nil {
+		t.Errorf("error was expected for an invalid selector, error was <nil>")
+	}
+}
+
+func errorStrings(errs []error) []string {
+	out := []string{}
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+func TestValidateContent(t *testing.T) {
+	var validateTestSets = []struct {
+		name string
+		data string
+		v    Validators
+		ok   bool
+	}{
+		{"object", `{"_shards": {"failed": 0}}`, Validators{{Selector: "._shards.failed", Expect: "0"}}, true},
+		{"object_failed", `{"_shards": {"failed": 2}}`, Validators{{Selector: "._shards.failed", Expect: "0"}}, false},
+		{"array", `[{"status": "ok"}, {"status": "ok"}]`, Validators{{Selector: "length", Expect: "2"}, {Selector: "all(.status == \"ok\")", Expect: "true"}}, true},
+		{"array_failed", `[{"status": "ok"}, {"status": "error"}]`, Validators{{Selector: "all(.status == \"ok\")", Expect: "true"}}, false},
+		{"scalar", `"ready"`, Validators{{Selector: ".", Expect: `"ready"`}}, true},
+		{"malformed", `[{"status": "ok"}`, Validators{{Selector: "length", Expect: "1"}}, false},
+	}
+
+	for _, test := range validateTestSets {
+		t.Run(test.name, func(t *testing.T) {
+			ok, errs := test.v.ValidateContent([]byte(test.data))
+			if ok != test.ok {
+				t.Errorf("validation result %t was expected, was %t: %v", test.ok, ok, errs)
+			}
+			if ok && len(errs) > 0 {
+				t.Errorf("no errors were expected, errors were %v", errs)
+			}
+		})
+	}
+}
@@ -3,22 +3,32 @@ module traductio
 go 1.13
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/aws/aws-lambda-go v1.26.0
 	github.com/aws/aws-sdk-go-v2 v1.11.2
 	github.com/aws/aws-sdk-go-v2/config v1.11.1
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.22.0
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.10.2
+	github.com/aws/aws-sdk-go-v2/service/ssm v1.17.1
 	github.com/aws/aws-sdk-go-v2/service/timestreamquery v1.9.0
 	github.com/aws/aws-sdk-go-v2/service/timestreamwrite v1.9.0
+	github.com/golang/snappy v0.0.4
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/influxdata/influxdb-client-go/v2 v2.4.0
 	github.com/itchyny/gojq v0.12.6
 	github.com/kr/pretty v0.2.0 // indirect
+	github.com/lib/pq v1.10.4
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/smartystreets/goconvey v1.7.2 // indirect
 	github.com/spf13/cobra v0.0.0-20170905172051-b78744579491
 	github.com/spf13/pflag v1.0.1-0.20170901120850-7aff26db30c1 // indirect
 	github.com/tj/go-naturaldate v1.3.0
+	github.com/xitongsys/parquet-go v1.6.2
+	go.opentelemetry.io/proto/otlp v0.11.0
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777
+	google.golang.org/grpc v1.42.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.3.0
+	modernc.org/sqlite v1.14.6
 )
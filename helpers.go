@@ -30,10 +30,9 @@ func exitOnErr(errs ...error) {
 			continue
 		}
 		errNotNil = true
-		fmt.Fprintf(os.Stderr, "ERROR: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 	}
 	if errNotNil {
-		fmt.Print("\n")
 		os.Exit(-1)
 	}
 }
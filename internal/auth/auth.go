@@ -0,0 +1,132 @@
+// Package auth provides the authentication methods available to HTTP(S)
+// inputs. Each method is implemented in its own package which registers
+// itself using the Register function.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	aMu sync.Mutex
+	a   = make(map[string]func(map[string]string) (Authenticator, error))
+)
+
+// Config keeps the configuration of the authentication method. The field
+// 'Kind' is used to determine which method is requested, all other fields
+// are passed to the method as parameters via the registered setup function.
+type Config struct {
+	Kind   string            `json:"kind" yaml:"kind"`
+	Params map[string]string `json:"params,omitempty" yaml:",inline"`
+}
+
+// sensitive lists the parameters holding secrets which are not to be
+// printed.
+var sensitive = []string{"password", "token", "client_secret"}
+
+// MarshalYAML renders the kind and the parameters inline like they are read
+// with the values of sensitive parameters replaced by '[REDACTED]'.
+func (c Config) MarshalYAML() (interface{}, error) {
+	out := map[string]string{}
+	for k, v := range c.Params {
+		out[k] = v
+	}
+	for _, k := range sensitive {
+		if _, ok := out[k]; ok {
+			out[k] = "[REDACTED]"
+		}
+	}
+	if c.Kind != "" {
+		out["kind"] = c.Kind
+	}
+	return out, nil
+}
+
+// Validate checks if the method requested is available.
+func (c Config) Validate() error {
+	if c.Kind == "" {
+		return nil
+	}
+	aMu.Lock()
+	defer aMu.Unlock()
+	if _, ok := a[c.Kind]; !ok {
+		return fmt.Errorf("auth of kind '%s' is not supported", c.Kind)
+	}
+	return nil
+}
+
+// Authenticator interface needs to be implemented in order to provide an
+// authentication method such as basic auth.
+type Authenticator interface {
+	// Transport returns a round tripper which authenticates the requests
+	// and passes them to the base transport provided.
+	Transport(base *http.Transport) (http.RoundTripper, error)
+}
+
+// Register must be called in the init function of each authentication
+// method. The Register function will panic if two methods with the same
+// name try to register themselves.
+func Register(name string, setupFunc func(map[string]string) (Authenticator, error)) {
+	aMu.Lock()
+	defer aMu.Unlock()
+	if _, dup := a[name]; dup {
+		panic("auth: Register called twice for method " + name)
+	}
+	a[name] = setupFunc
+}
+
+// New will return a configured instance of an authentication method. The
+// method requested is determined by the 'Kind' field of the configuration
+// struct.
+func New(conf Config) (Authenticator, error) {
+	aMu.Lock()
+	setupFunc, ok := a[conf.Kind]
+	aMu.Unlock()
+	if !ok {
+		return nil, errors.New("auth: The method '" + conf.Kind + "' does not exist")
+	}
+	params := conf.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+	return setupFunc(params)
+}
+
+// Secret looks up the value of the parameter 'key'. Secrets can either be
+// provided as is, read from the file specified in '<key>_file' or read from
+// the environment variable specified in '<key>_env'. The bool returned
+// reports whether the secret was provided in any of these ways.
+func Secret(params map[string]string, key string) (string, bool, error) {
+	if v, ok := params[key]; ok && v != "" {
+		return v, true, nil
+	}
+	if path, ok := params[key+"_file"]; ok && path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", true, fmt.Errorf("error while reading '%s' from file %s: %s", key, path, err.Error())
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	if name, ok := params[key+"_env"]; ok && name != "" {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", true, fmt.Errorf("environment variable %s to read '%s' from is not set", name, key)
+		}
+		return v, true, nil
+	}
+	return "", false, nil
+}
+
+// RoundTripperFunc allows to use an ordinary function as round tripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,42 @@
+package basic
+
+import (
+	"fmt"
+	"net/http"
+	"traductio/internal/auth"
+)
+
+func init() {
+	auth.Register("basic", setup)
+}
+
+type Basic struct {
+	Username string
+	Password string
+}
+
+func setup(params map[string]string) (auth.Authenticator, error) {
+	b := Basic{}
+
+	var found bool
+	var err error
+
+	if b.Username, found = params["username"]; !found || b.Username == "" {
+		return b, fmt.Errorf("auth requires field 'username' to be set")
+	}
+	if b.Password, found, err = auth.Secret(params, "password"); err != nil {
+		return b, err
+	} else if !found {
+		return b, fmt.Errorf("auth requires one of the fields 'password', 'password_file' or 'password_env' to be set")
+	}
+
+	return b, nil
+}
+
+func (b Basic) Transport(base *http.Transport) (http.RoundTripper, error) {
+	return auth.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		r.SetBasicAuth(b.Username, b.Password)
+		return base.RoundTrip(r)
+	}), nil
+}
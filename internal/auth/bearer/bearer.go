@@ -0,0 +1,38 @@
+package bearer
+
+import (
+	"fmt"
+	"net/http"
+	"traductio/internal/auth"
+)
+
+func init() {
+	auth.Register("bearer", setup)
+}
+
+type Bearer struct {
+	Token string
+}
+
+func setup(params map[string]string) (auth.Authenticator, error) {
+	b := Bearer{}
+
+	var found bool
+	var err error
+
+	if b.Token, found, err = auth.Secret(params, "token"); err != nil {
+		return b, err
+	} else if !found || b.Token == "" {
+		return b, fmt.Errorf("auth requires one of the fields 'token', 'token_file' or 'token_env' to be set")
+	}
+
+	return b, nil
+}
+
+func (b Bearer) Transport(base *http.Transport) (http.RoundTripper, error) {
+	return auth.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		r.Header.Set("Authorization", "Bearer "+b.Token)
+		return base.RoundTrip(r)
+	}), nil
+}
@@ -0,0 +1,64 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"traductio/internal/auth"
+)
+
+func init() {
+	auth.Register("mtls", setup)
+}
+
+type MTLS struct {
+	Certificate tls.Certificate
+	RootCAs     *x509.CertPool
+}
+
+func setup(params map[string]string) (auth.Authenticator, error) {
+	m := MTLS{}
+
+	var found bool
+	var certFile, keyFile, caFile string
+
+	if certFile, found = params["cert_file"]; !found || certFile == "" {
+		return m, fmt.Errorf("auth requires field 'cert_file' to be set")
+	}
+	if keyFile, found = params["key_file"]; !found || keyFile == "" {
+		return m, fmt.Errorf("auth requires field 'key_file' to be set")
+	}
+
+	var err error
+	m.Certificate, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return m, fmt.Errorf("error while loading client certificate %s: %s", certFile, err.Error())
+	}
+
+	if caFile = params["ca_file"]; caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return m, fmt.Errorf("error while reading CA bundle %s: %s", caFile, err.Error())
+		}
+		m.RootCAs = x509.NewCertPool()
+		if !m.RootCAs.AppendCertsFromPEM(pem) {
+			return m, fmt.Errorf("CA bundle %s does not contain any PEM encoded certificate", caFile)
+		}
+	}
+
+	return m, nil
+}
+
+func (m MTLS) Transport(base *http.Transport) (http.RoundTripper, error) {
+	t := base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.Certificates = []tls.Certificate{m.Certificate}
+	if m.RootCAs != nil {
+		t.TLSClientConfig.RootCAs = m.RootCAs
+	}
+	return t, nil
+}
@@ -0,0 +1,155 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"traductio/internal/auth"
+)
+
+func init() {
+	auth.Register("oauth2", setup)
+}
+
+// expiryDelta is subtracted from the lifetime of a token to avoid using it
+// just before it expires.
+const expiryDelta = 30 * time.Second
+
+// OAuth2 implements the OAuth 2.0 client credentials flow. Tokens are cached
+// until they expire.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AuthStyle    string
+
+	cache *tokenCache
+}
+
+type tokenCache struct {
+	sync.Mutex
+	authorization string
+	expiry        time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func setup(params map[string]string) (auth.Authenticator, error) {
+	o := OAuth2{cache: &tokenCache{}}
+
+	var found bool
+	var err error
+
+	if o.TokenURL, found = params["token_url"]; !found || o.TokenURL == "" {
+		return o, fmt.Errorf("auth requires field 'token_url' to be set")
+	}
+	if o.ClientID, found = params["client_id"]; !found || o.ClientID == "" {
+		return o, fmt.Errorf("auth requires field 'client_id' to be set")
+	}
+	if o.ClientSecret, found, err = auth.Secret(params, "client_secret"); err != nil {
+		return o, err
+	} else if !found {
+		return o, fmt.Errorf("auth requires one of the fields 'client_secret', 'client_secret_file' or 'client_secret_env' to be set")
+	}
+	o.Scopes = strings.Fields(params["scopes"])
+
+	switch o.AuthStyle = params["auth_style"]; o.AuthStyle {
+	case "":
+		o.AuthStyle = "header"
+	case "header", "params":
+	default:
+		return o, fmt.Errorf("auth field 'auth_style' must be either 'header' or 'params'")
+	}
+
+	return o, nil
+}
+
+func (o OAuth2) Transport(base *http.Transport) (http.RoundTripper, error) {
+	client := &http.Client{Transport: base}
+	return auth.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		authorization, err := o.authorization(req.Context(), client)
+		if err != nil {
+			return nil, err
+		}
+		r := req.Clone(req.Context())
+		r.Header.Set("Authorization", authorization)
+		return base.RoundTrip(r)
+	}), nil
+}
+
+// authorization returns the value of the authorization header, a new token
+// is requested if the cached one has expired.
+func (o OAuth2) authorization(ctx context.Context, client *http.Client) (string, error) {
+	o.cache.Lock()
+	defer o.cache.Unlock()
+
+	if o.cache.authorization != "" && time.Now().Before(o.cache.expiry) {
+		return o.cache.authorization, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	if o.AuthStyle == "params" {
+		form.Set("client_id", o.ClientID)
+		form.Set("client_secret", o.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error while creating token request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.AuthStyle == "header" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error while requesting token from %s: %s", o.TokenURL, err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error while reading token from %s: %s", o.TokenURL, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %s returned HTTP status code %d: %s", o.TokenURL, resp.StatusCode, string(body))
+	}
+
+	var t tokenResponse
+	err = json.Unmarshal(body, &t)
+	if err != nil {
+		return "", fmt.Errorf("error while parsing token from %s: %s", o.TokenURL, err.Error())
+	}
+	if t.AccessToken == "" {
+		return "", fmt.Errorf("token response from %s does not contain an access token", o.TokenURL)
+	}
+
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	o.cache.authorization = tokenType + " " + t.AccessToken
+	o.cache.expiry = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - expiryDelta)
+	if t.ExpiresIn == 0 {
+		// tokens without expiry are used for the remainder of the run
+		o.cache.expiry = time.Now().Add(24 * time.Hour)
+	}
+
+	return o.cache.authorization, nil
+}
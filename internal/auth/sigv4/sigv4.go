@@ -0,0 +1,94 @@
+package sigv4
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"traductio/internal/auth"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+func init() {
+	auth.Register("sigv4", setup)
+}
+
+const defaultService = "es"
+
+// SigV4 signs requests using AWS Signature Version 4 with the default AWS
+// credential chain, for example to query Amazon OpenSearch Service with IAM
+// authentication.
+type SigV4 struct {
+	Signer      *v4.Signer
+	Credentials aws.CredentialsProvider
+	Service     string
+	Region      string
+}
+
+func setup(params map[string]string) (auth.Authenticator, error) {
+	s := SigV4{
+		Signer:  v4.NewSigner(),
+		Service: params["service"],
+		Region:  params["region"],
+	}
+
+	awscfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return s, fmt.Errorf("error while loading AWS config to sign requests: %s", err.Error())
+	}
+	s.Credentials = awscfg.Credentials
+
+	if s.Service == "" {
+		s.Service = defaultService
+	}
+	if s.Region == "" {
+		s.Region = awscfg.Region
+	}
+	if s.Region == "" {
+		return s, fmt.Errorf("auth requires field 'region' to be set or a region to be configured for AWS")
+	}
+	if s.Credentials == nil {
+		return s, fmt.Errorf("auth requires AWS credentials to be available")
+	}
+
+	return s, nil
+}
+
+func (s SigV4) Transport(base *http.Transport) (http.RoundTripper, error) {
+	return auth.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := []byte{}
+		if req.GetBody != nil {
+			rc, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			body, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		hash := sha256.Sum256(body)
+
+		creds, err := s.Credentials.Retrieve(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("error while retrieving AWS credentials: %s", err.Error())
+		}
+
+		r := req.Clone(req.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		err = s.Signer.SignHTTP(req.Context(), creds, r, hex.EncodeToString(hash[:]), s.Service, s.Region, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("error while signing request: %s", err.Error())
+		}
+
+		return base.RoundTrip(r)
+	}), nil
+}
@@ -0,0 +1,27 @@
+package inputreader
+
+import (
+	"net/http"
+	"traductio/internal/auth"
+)
+
+// client returns the HTTP client to be used to perform the requests,
+// authenticated as configured.
+func (in Input) client() (*http.Client, error) {
+	if in.Auth.Kind == "" {
+		return &http.Client{}, nil
+	}
+
+	a, err := auth.New(in.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	t, err := a.Transport(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: t}, nil
+}
@@ -2,10 +2,15 @@ package inputreader
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"strings"
-	"text/template"
+	"time"
+	"traductio/internal/auth"
 )
 
 type InputConfig struct {
@@ -14,6 +19,16 @@ type InputConfig struct {
 	Method           string            `json:"method" yaml:"method"`
 	Body             string            `json:"body" yaml:"body"`
 	HTTPExpectStatus int               `json:"http_expect_status" yaml:"http_expect_status"`
+	Pagination       PaginationConfig  `json:"pagination" yaml:"pagination"`
+	Timeout          time.Duration     `json:"timeout" yaml:"timeout"`
+	Retries          int               `json:"retries" yaml:"retries"`
+	Backoff          time.Duration     `json:"backoff" yaml:"backoff"`
+	RetryOnStatus    []int             `json:"retry_on_status" yaml:"retry_on_status"`
+	Auth             auth.Config       `json:"auth" yaml:"auth"`
+	Stream           bool              `json:"stream" yaml:"stream"`
+	Format           string            `json:"format" yaml:"format"`
+	Lines            string            `json:"lines" yaml:"lines"`
+	OnMalformed      string            `json:"on_malformed" yaml:"on_malformed"`
 }
 
 type Input struct {
@@ -22,81 +37,263 @@ type Input struct {
 	Method           string            `json:"method" yaml:"method"`
 	Body             string            `json:"body" yaml:"body"`
 	HTTPExpectStatus int               `json:"http_expect_status" yaml:"http_expect_status"`
+	Pagination       PaginationConfig  `json:"pagination,omitempty" yaml:"pagination,omitempty"`
+	Timeout          time.Duration     `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Retries          int               `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Backoff          time.Duration     `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	RetryOnStatus    []int             `json:"retry_on_status,omitempty" yaml:"retry_on_status,omitempty"`
+	Auth             auth.Config       `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Stream           bool              `json:"stream,omitempty" yaml:"stream,omitempty"`
+	Format           string            `json:"format,omitempty" yaml:"format,omitempty"`
+	Lines            string            `json:"lines,omitempty" yaml:"lines,omitempty"`
+	OnMalformed      string            `json:"on_malformed,omitempty" yaml:"on_malformed,omitempty"`
+
+	// Info is called to report progress such as the attempts made to read
+	// the data, it can be left unset
+	Info func(string) `json:"-" yaml:"-"`
+
+	// the config and the vars are kept to render follow-up requests when
+	// paginating
+	config InputConfig
+	vars   map[string]string
 }
 
 func NewInput(c InputConfig, vars map[string]string) (Input, error) {
 	// prepare input
 	in := Input{
 		Method:           c.Method,
-		Headers:          map[string]string{},
 		HTTPExpectStatus: c.HTTPExpectStatus,
+		Pagination:       c.Pagination,
+		Timeout:          c.Timeout,
+		Retries:          c.Retries,
+		Backoff:          c.Backoff,
+		RetryOnStatus:    c.RetryOnStatus,
+		Stream:           c.Stream,
+		Format:           c.Format,
+		Lines:            c.Lines,
+		OnMalformed:      c.OnMalformed,
+		config:           c,
+		vars:             map[string]string{},
+	}
+	for k, v := range vars {
+		in.vars[k] = v
 	}
 
-	// rendering func
-	renderTemplate := func(t, hint string, v map[string]string) (string, error) {
-		templ, err := template.New("template").Funcs(getTemplateFuncMap()).Parse(t)
-		if err != nil {
-			return "", fmt.Errorf("could not parse %s template: %s", hint, err.Error())
-		}
-		b := &bytes.Buffer{}
-		err = templ.Execute(b, v)
-		if err != nil {
-			return "", fmt.Errorf("could not render %s template: %s", hint, err.Error())
-		}
-		return b.String(), nil
+	// validating pagination, retries and auth
+	err := in.Pagination.Validate()
+	if err != nil {
+		return in, err
+	}
+	err = c.Auth.Validate()
+	if err != nil {
+		return in, err
+	}
+	if in.Retries < 0 {
+		return in, fmt.Errorf("field 'retries' cannot be negative")
+	}
+	if in.Stream && in.Pagination.Kind != "" {
+		return in, fmt.Errorf("field 'stream' cannot be used together with pagination")
+	}
+	err = in.validateFormat()
+	if err != nil {
+		return in, err
+	}
+	switch in.Pagination.Kind {
+	case PaginationComposite:
+		in.vars["after_key"] = ""
+	case PaginationCursor:
+		in.vars["cursor"] = ""
+	case PaginationOffset:
+		in.vars["offset"] = "0"
+		in.vars["limit"] = fmt.Sprintf("%d", in.Pagination.Limit)
 	}
 
+	err = in.render()
+	return in, err
+}
+
+// render renders the templated fields of the config using the vars of the
+// input.
+func (in *Input) render() error {
 	var err error
 
 	// redering body
-	in.Body, err = renderTemplate(c.Body, "body", vars)
+	in.Body, err = RenderTemplate(in.config.Body, "body", in.vars)
 	if err != nil {
-		return in, err
+		return err
 	}
 
 	// rendering URL
-	in.URL, err = renderTemplate(c.URL, "body", vars)
+	in.URL, err = RenderTemplate(in.config.URL, "url", in.vars)
 	if err != nil {
-		return in, err
+		return err
 	}
 
 	// rendering headers
-	for k, v := range c.Headers {
-		name, err := renderTemplate(k, fmt.Sprintf("header name '%s'", k), vars)
+	in.Headers = map[string]string{}
+	for k, v := range in.config.Headers {
+		name, err := RenderTemplate(k, fmt.Sprintf("header name '%s'", k), in.vars)
+		if err != nil {
+			return err
+		}
+
+		in.Headers[name], err = RenderTemplate(v, fmt.Sprintf("header value '%s'", k), in.vars)
 		if err != nil {
-			return in, err
+			return err
 		}
+	}
 
-		in.Headers[name], err = renderTemplate(v, fmt.Sprintf("header value '%s'", k), vars)
+	// rendering auth params
+	in.Auth = auth.Config{Kind: in.config.Auth.Kind}
+	if len(in.config.Auth.Params) > 0 {
+		in.Auth.Params = map[string]string{}
+	}
+	for k, v := range in.config.Auth.Params {
+		in.Auth.Params[k], err = RenderTemplate(v, fmt.Sprintf("auth param '%s'", k), in.vars)
 		if err != nil {
-			return in, err
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetch reads the data specified by the input as a single document. Unless
+// the input is paginated or its lines are to be processed separately the
+// data is not buffered but read from its source while consuming the reader
+// returned, which has to be closed by the caller. Newline-delimited JSON is
+// presented as JSON array of its lines. Use FetchPages if the pages of a
+// paginated input or the lines are to be processed separately.
+func (in Input) Fetch() (io.ReadCloser, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	if in.Format == FormatNDJSON && in.Lines == LinesSeparate {
+		// the lines are returned as pages below
+	} else if u.Scheme == "" {
+		r, err = in.retryOpen(u.Path, func(ctx context.Context) (io.ReadCloser, error) {
+			return openFile(u.Path)
+		})
+	} else if (u.Scheme == "http" || u.Scheme == "https") && in.Pagination.Kind == "" {
+		var client *http.Client
+		client, err = in.client()
+		if err == nil {
+			r, err = in.openHypertext(client)
+		}
+	} else if u.Scheme == "s3" {
+		r, err = in.retryOpen(in.URL, func(ctx context.Context) (io.ReadCloser, error) {
+			return openS3(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		if in.Format == FormatNDJSON {
+			return in.ndjsonReader(r), nil
 		}
+		return r, nil
 	}
 
-	return in, nil
+	pages, err := in.FetchPages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) != 1 {
+		return nil, fmt.Errorf("input returned %d pages to be processed separately, a single document was expected", len(pages))
+	}
+	return ioutil.NopCloser(bytes.NewReader(pages[0])), nil
 }
 
-func (in Input) Fetch() ([]byte, error) {
+// FetchPages reads the data specified by the input. Unless the pages of a
+// paginated input are to be processed separately a single document is
+// returned.
+func (in Input) FetchPages() ([][]byte, error) {
 	var err error
 	var data []byte
-	var status int
 
 	u, err := url.Parse(in.URL)
 	if err != nil {
-		return []byte{}, err
+		return [][]byte{}, err
 	}
 
 	if u.Scheme == "" {
-		return readFile(u.Path)
+		err = in.retry(u.Path, func(ctx context.Context) error {
+			data, err = readFile(ctx, u.Path)
+			return err
+		})
 	} else if u.Scheme == "http" || u.Scheme == "https" {
-		data, status, err = readHypertext(in.URL, in.Body, in.Method, in.Headers)
-		if err == nil && in.HTTPExpectStatus != 0 && status != in.HTTPExpectStatus {
-			return data, fmt.Errorf("HTTP status code is %d, %d was expected", status, in.HTTPExpectStatus)
+		var client *http.Client
+		client, err = in.client()
+		if err != nil {
+			return [][]byte{}, err
+		}
+		if in.Pagination.Kind != "" {
+			return in.fetchPaginated(client)
 		}
-		return data, err
+		data, _, err = in.fetchHypertext(client)
 	} else if u.Scheme == "s3" {
-		return readS3(u.Host, strings.TrimPrefix(u.Path, "/"))
+		err = in.retry(in.URL, func(ctx context.Context) error {
+			data, err = readS3(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+			return err
+		})
 	} else {
-		return data, fmt.Errorf("cannot read %s: unsupported protocol %s", in.URL, u.Scheme)
+		err = fmt.Errorf("cannot read %s: unsupported protocol %s", in.URL, u.Scheme)
+	}
+	if err != nil || in.Format != FormatNDJSON {
+		return [][]byte{data}, err
+	}
+
+	lines, err := in.ndjsonLines(bytes.NewReader(data)).split()
+	if err != nil {
+		return [][]byte{}, err
 	}
+	if in.Lines == LinesSeparate {
+		return lines, nil
+	}
+	return [][]byte{append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')}, nil
+}
+
+// openHypertext sends the request of the input and returns the body of the
+// response once its status code is acceptable.
+func (in Input) openHypertext(client *http.Client) (io.ReadCloser, error) {
+	return in.retryOpen(in.URL, func(ctx context.Context) (io.ReadCloser, error) {
+		r, status, _, err := openHypertext(ctx, client, in.URL, in.Body, in.Method, in.Headers)
+		if err != nil {
+			return nil, err
+		}
+		if in.retryOnStatus(status) {
+			r.Close()
+			return nil, fmt.Errorf("HTTP status code is %d", status)
+		}
+		if in.HTTPExpectStatus != 0 && status != in.HTTPExpectStatus {
+			r.Close()
+			return nil, permanent(fmt.Errorf("HTTP status code is %d, %d was expected", status, in.HTTPExpectStatus))
+		}
+		return r, nil
+	})
+}
+
+func (in Input) fetchHypertext(client *http.Client) ([]byte, http.Header, error) {
+	var data []byte
+	var header http.Header
+	err := in.retry(in.URL, func(ctx context.Context) error {
+		var status int
+		var err error
+		data, status, header, err = readHypertext(ctx, client, in.URL, in.Body, in.Method, in.Headers)
+		if err != nil {
+			return err
+		}
+		if in.retryOnStatus(status) {
+			return fmt.Errorf("HTTP status code is %d", status)
+		}
+		if in.HTTPExpectStatus != 0 && status != in.HTTPExpectStatus {
+			return permanent(fmt.Errorf("HTTP status code is %d, %d was expected", status, in.HTTPExpectStatus))
+		}
+		return nil
+	})
+	return data, header, err
 }
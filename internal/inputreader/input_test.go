@@ -0,0 +1,157 @@
+package inputreader
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fetch reads the whole document returned by Fetch.
+func fetch(in Input) ([]byte, error) {
+	r, err := in.Fetch()
+	if err != nil {
+		return []byte{}, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestFetchStream(t *testing.T) {
+	// the handler blocks after the first chunk until the test has read it
+	read := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items":[`)
+		w.(http.Flusher).Flush()
+		<-read
+		fmt.Fprint(w, `1,2]}`)
+	}))
+	defer server.Close()
+
+	in, err := NewInput(InputConfig{URL: server.URL, Stream: true}, map[string]string{})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	r, err := in.Fetch()
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	defer r.Close()
+
+	chunk := make([]byte, 10)
+	if _, err := r.Read(chunk); err != nil || string(chunk) != `{"items":[` {
+		t.Fatalf("first chunk is not as expected: '%s' (%v)", string(chunk), err)
+	}
+	close(read)
+	rest, err := ioutil.ReadAll(r)
+	if err != nil || string(rest) != `1,2]}` {
+		t.Errorf("rest is not as expected: '%s' (%v)", string(rest), err)
+	}
+}
+
+func TestFetchStreamTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		stall       bool
+		errExpected bool
+	}{
+		// the stream and the consumer take several times the timeout as a whole
+		{name: "progressing", stall: false, errExpected: false},
+		{name: "stalled", stall: true, errExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			done := make(chan bool)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"items":[0`)
+				w.(http.Flusher).Flush()
+				if test.stall {
+					select {
+					case <-done:
+					case <-r.Context().Done():
+					}
+					return
+				}
+				for i := 1; i < 10; i++ {
+					time.Sleep(20 * time.Millisecond)
+					fmt.Fprintf(w, ",%d", i)
+					w.(http.Flusher).Flush()
+				}
+				fmt.Fprint(w, `]}`)
+			}))
+			defer server.Close()
+			defer close(done)
+
+			in, err := NewInput(InputConfig{URL: server.URL, Stream: true, Timeout: 50 * time.Millisecond}, map[string]string{})
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			r, err := in.Fetch()
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			defer r.Close()
+
+			// the consumer takes its time between the reads, e.g. to write to a sink
+			data := []byte{}
+			chunk := make([]byte, 4)
+			for {
+				n, err := r.Read(chunk)
+				data = append(data, chunk[:n]...)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					if !test.errExpected {
+						t.Errorf("no error was expected, error was '%s'", err)
+					}
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if test.errExpected {
+				t.Errorf("error was expected once no data was received within the timeout, error was <nil>")
+			}
+			if string(data) != `{"items":[0,1,2,3,4,5,6,7,8,9]}` {
+				t.Errorf("data is not as expected: '%s'", string(data))
+			}
+		})
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := ioutil.WriteFile(path, []byte(`{"ok":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewInput(InputConfig{URL: path, Stream: true}, map[string]string{})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	data, err := fetch(in)
+	if err != nil || string(data) != `{"ok":true}` {
+		t.Errorf("data is not as expected: '%s' (%v)", string(data), err)
+	}
+
+	in.URL = path + ".missing"
+	if _, err := fetch(in); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("error was expected for a missing file, error was '%v'", err)
+	}
+}
+
+func TestStreamPagination(t *testing.T) {
+	c := InputConfig{
+		URL:        "https://api.example.com/v1/stats?cursor={{.cursor}}",
+		Stream:     true,
+		Pagination: PaginationConfig{Kind: PaginationCursor, Next: ".next"},
+	}
+	if _, err := NewInput(c, map[string]string{}); err == nil {
+		t.Errorf("error was expected for a paginated stream, error was <nil>")
+	}
+}
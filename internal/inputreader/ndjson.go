@@ -0,0 +1,159 @@
+package inputreader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// FormatJSON reads the data as a single JSON document.
+	FormatJSON = "json"
+	// FormatNDJSON reads the data as newline-delimited JSON, e.g. one JSON
+	// value per line.
+	FormatNDJSON = "ndjson"
+
+	// LinesArray presents the lines of newline-delimited JSON as elements
+	// of a single top-level array.
+	LinesArray = "array"
+	// LinesSeparate returns every line to be processed separately.
+	LinesSeparate = "separate"
+
+	// MalformedFail fails reading the data at the first malformed line.
+	MalformedFail = "fail"
+	// MalformedSkip reports and skips malformed lines.
+	MalformedSkip = "skip"
+)
+
+// validateFormat checks if the format of the input and its options are
+// supported.
+func (in Input) validateFormat() error {
+	switch in.Format {
+	case "", FormatJSON:
+		if in.Lines != "" || in.OnMalformed != "" {
+			return fmt.Errorf("fields 'lines' and 'on_malformed' require format '%s'", FormatNDJSON)
+		}
+		return nil
+	case FormatNDJSON:
+	default:
+		return fmt.Errorf("format '%s' is not supported", in.Format)
+	}
+
+	switch in.Lines {
+	case "", LinesArray:
+	case LinesSeparate:
+		if in.Stream {
+			return fmt.Errorf("field 'stream' requires lines '%s'", LinesArray)
+		}
+	default:
+		return fmt.Errorf("lines '%s' is not supported", in.Lines)
+	}
+
+	switch in.OnMalformed {
+	case "", MalformedFail, MalformedSkip:
+	default:
+		return fmt.Errorf("on_malformed '%s' is not supported", in.OnMalformed)
+	}
+
+	if in.Pagination.Kind != "" {
+		return fmt.Errorf("format '%s' cannot be used together with pagination", FormatNDJSON)
+	}
+	return nil
+}
+
+// ndjsonLines reads the lines of newline-delimited JSON. Empty lines are
+// ignored, malformed ones fail or are skipped depending on the input.
+type ndjsonLines struct {
+	in    Input
+	r     *bufio.Reader
+	line  int
+	valid int
+}
+
+func (in Input) ndjsonLines(r io.Reader) *ndjsonLines {
+	return &ndjsonLines{in: in, r: bufio.NewReader(r)}
+}
+
+// next returns the next valid line, io.EOF once all lines are read.
+func (l *ndjsonLines) next() ([]byte, error) {
+	for {
+		data, err := l.r.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error while reading line %d: %s", l.line+1, err.Error())
+		}
+		l.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		var v json.RawMessage
+		if err := json.Unmarshal(data, &v); err != nil {
+			if l.in.OnMalformed != MalformedSkip {
+				return nil, fmt.Errorf("line %d is not valid JSON: %s", l.line, err.Error())
+			}
+			l.in.info(fmt.Sprintf("Skipping line %d which is not valid JSON: %s", l.line, err.Error()))
+			continue
+		}
+		l.valid++
+		return data, nil
+	}
+}
+
+// split returns the valid lines of the data.
+func (l *ndjsonLines) split() ([][]byte, error) {
+	lines := [][]byte{}
+	for {
+		data, err := l.next()
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return lines, err
+		}
+		lines = append(lines, data)
+	}
+}
+
+// ndjsonReader presents newline-delimited JSON read from its source as a
+// JSON array of the lines without reading the source as a whole.
+type ndjsonReader struct {
+	lines  *ndjsonLines
+	source io.Closer
+	buf    []byte
+	done   bool
+}
+
+func (in Input) ndjsonReader(r io.ReadCloser) *ndjsonReader {
+	return &ndjsonReader{lines: in.ndjsonLines(r), source: r, buf: []byte("[")}
+}
+
+func (r *ndjsonReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		data, err := r.lines.next()
+		if err == io.EOF {
+			r.buf = []byte("]")
+			r.done = true
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if r.lines.valid > 1 {
+			r.buf = append(r.buf, ',')
+		}
+		r.buf = append(r.buf, data...)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *ndjsonReader) Close() error {
+	return r.source.Close()
+}
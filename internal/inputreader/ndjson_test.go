@@ -0,0 +1,150 @@
+package inputreader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ndjson = `{"status": 200, "bytes": 512}
+{"status": 404, "bytes": 0}
+
+{"status": 500, "bytes":
+[1, 2]
+`
+
+func TestNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.ndjson")
+	if err := ioutil.WriteFile(path, []byte(ndjson), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ndjsonTestSets = []struct {
+		name        string
+		c           InputConfig
+		stream      bool
+		pages       []string
+		reported    []string
+		errExpected string
+	}{
+		{
+			name:        "fail",
+			c:           InputConfig{Format: FormatNDJSON},
+			errExpected: "line 4 is not valid JSON",
+		},
+		{
+			name:        "fail_stream",
+			c:           InputConfig{Format: FormatNDJSON, Stream: true},
+			stream:      true,
+			errExpected: "line 4 is not valid JSON",
+		},
+		{
+			name:     "skip",
+			c:        InputConfig{Format: FormatNDJSON, OnMalformed: MalformedSkip},
+			pages:    []string{`[{"status": 200, "bytes": 512},{"status": 404, "bytes": 0},[1, 2]]`},
+			reported: []string{"Skipping line 4 which is not valid JSON: unexpected end of JSON input"},
+		},
+		{
+			name:     "skip_stream",
+			c:        InputConfig{Format: FormatNDJSON, OnMalformed: MalformedSkip, Stream: true},
+			stream:   true,
+			pages:    []string{`[{"status": 200, "bytes": 512},{"status": 404, "bytes": 0},[1, 2]]`},
+			reported: []string{"Skipping line 4 which is not valid JSON: unexpected end of JSON input"},
+		},
+		{
+			name:     "separate",
+			c:        InputConfig{Format: FormatNDJSON, Lines: LinesSeparate, OnMalformed: MalformedSkip},
+			pages:    []string{`{"status": 200, "bytes": 512}`, `{"status": 404, "bytes": 0}`, `[1, 2]`},
+			reported: []string{"Skipping line 4 which is not valid JSON: unexpected end of JSON input"},
+		},
+	}
+
+	for _, test := range ndjsonTestSets {
+		t.Run(test.name, func(t *testing.T) {
+			test.c.URL = path
+			in, err := NewInput(test.c, map[string]string{})
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			reported := []string{}
+			in.Info = func(msg string) {
+				if strings.HasPrefix(msg, "Skipping") {
+					reported = append(reported, msg)
+				}
+			}
+
+			var pages []string
+			if test.stream {
+				var data []byte
+				data, err = fetch(in)
+				pages = []string{string(data)}
+			} else {
+				var data [][]byte
+				data, err = in.FetchPages()
+				for _, page := range data {
+					pages = append(pages, string(page))
+				}
+			}
+
+			if test.errExpected != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errExpected) {
+					t.Errorf("error containing '%s' was expected, error was '%v'", test.errExpected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if !reflect.DeepEqual(pages, test.pages) {
+				t.Errorf("pages are not as expected: %q", pages)
+			}
+			if !reflect.DeepEqual(reported, test.reported) {
+				t.Errorf("reported messages are not as expected: %q", reported)
+			}
+		})
+	}
+}
+
+func TestNDJSONHypertext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "{\"n\":%d}\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	in, err := NewInput(InputConfig{URL: server.URL, Format: FormatNDJSON, Stream: true}, map[string]string{})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	data, err := fetch(in)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	if string(data) != `[{"n":0},{"n":1},{"n":2}]` {
+		t.Errorf("data is not as expected: '%s'", string(data))
+	}
+}
+
+func TestNDJSONValidate(t *testing.T) {
+	var invalid = []InputConfig{
+		{Format: "xml"},
+		{Lines: LinesSeparate},
+		{Format: FormatNDJSON, Lines: "objects"},
+		{Format: FormatNDJSON, OnMalformed: "ignore"},
+		{Format: FormatNDJSON, Lines: LinesSeparate, Stream: true},
+		{Format: FormatNDJSON, Pagination: PaginationConfig{Kind: PaginationLink, Merge: MergeSeparate}},
+	}
+	for _, c := range invalid {
+		c.URL = "https://logs.example.com/export"
+		if _, err := NewInput(c, map[string]string{}); err == nil {
+			t.Errorf("error was expected for %+v, error was <nil>", c)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package inputreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+const (
+	// PaginationComposite follows the 'after_key' of an ElasticSearch
+	// composite aggregation.
+	PaginationComposite = "composite"
+	// PaginationLink follows the 'Link' header with 'rel=next'.
+	PaginationLink = "link"
+	// PaginationCursor follows a cursor provided in the response body.
+	PaginationCursor = "cursor"
+	// PaginationOffset increments an offset by a fixed limit.
+	PaginationOffset = "offset"
+
+	// MergeConcat concatenates the items of all pages into the first page.
+	MergeConcat = "concat"
+	// MergeSeparate returns all pages to be processed separately.
+	MergeSeparate = "separate"
+
+	defaultMaxPages = 1000
+)
+
+// PaginationConfig describes how follow-up requests are issued when the data
+// requested does not fit into a single response. The URL, the headers and the
+// body are rendered again for every follow-up request with some additional
+// vars depending on the kind of pagination:
+//
+// 'composite' follows the 'after_key' of an ElasticSearch composite
+// aggregation selected by 'next'. The JSON encoded key is available as
+// '{{.after_key}}'. Requests are issued until no key or no items are
+// returned anymore.
+//
+// 'cursor' follows the cursor selected by 'next', which is available as
+// '{{.cursor}}'. Requests are issued until no cursor is returned anymore.
+//
+// 'offset' provides '{{.offset}}' and '{{.limit}}', the offset is incremented
+// by 'limit' for every page. Requests are issued until a page returns less
+// than 'limit' items.
+//
+// 'link' requests the URL of the 'Link' header with 'rel=next' until no such
+// header is returned anymore.
+//
+// With 'merge' set to 'concat' (the default) the lists selected by 'items' of
+// all pages are concatenated into the first page. With 'merge' set to
+// 'separate' all pages are returned to be processed one by one. To avoid
+// endless loops at most 'max_pages' are requested.
+type PaginationConfig struct {
+	Kind     string `json:"kind" yaml:"kind"`
+	Next     string `json:"next,omitempty" yaml:"next,omitempty"`
+	Items    string `json:"items,omitempty" yaml:"items,omitempty"`
+	Limit    int    `json:"limit,omitempty" yaml:"limit,omitempty"`
+	MaxPages int    `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`
+	Merge    string `json:"merge,omitempty" yaml:"merge,omitempty"`
+}
+
+// Validate checks if the pagination configuration is complete.
+func (p PaginationConfig) Validate() error {
+	if p.Kind == "" {
+		return nil
+	}
+
+	switch p.Kind {
+	case PaginationComposite, PaginationCursor:
+		if p.Next == "" {
+			return fmt.Errorf("pagination of kind '%s' requires field 'next' to be set", p.Kind)
+		}
+	case PaginationOffset:
+		if p.Limit < 1 {
+			return fmt.Errorf("pagination of kind '%s' requires field 'limit' to be set", p.Kind)
+		}
+		if p.Items == "" {
+			return fmt.Errorf("pagination of kind '%s' requires field 'items' to be set", p.Kind)
+		}
+	case PaginationLink:
+	default:
+		return fmt.Errorf("pagination of kind '%s' is not supported", p.Kind)
+	}
+
+	switch p.Merge {
+	case "", MergeConcat:
+		if p.Items == "" {
+			return fmt.Errorf("pagination with merge '%s' requires field 'items' to be set", MergeConcat)
+		}
+	case MergeSeparate:
+	default:
+		return fmt.Errorf("pagination merge '%s' is not supported", p.Merge)
+	}
+
+	if p.MaxPages < 0 {
+		return fmt.Errorf("pagination field 'max_pages' cannot be negative")
+	}
+
+	return nil
+}
+
+func (in Input) fetchPaginated(client *http.Client) ([][]byte, error) {
+	p := in.Pagination
+
+	var next, items *gojq.Code
+	var err error
+	if p.Next != "" {
+		next, err = compileQuery(p.Next)
+		if err != nil {
+			return [][]byte{}, fmt.Errorf("could not compile pagination selector 'next': %s", err.Error())
+		}
+	}
+	if p.Items != "" {
+		items, err = compileQuery(p.Items)
+		if err != nil {
+			return [][]byte{}, fmt.Errorf("could not compile pagination selector 'items': %s", err.Error())
+		}
+	}
+
+	maxPages := p.MaxPages
+	if maxPages == 0 {
+		maxPages = defaultMaxPages
+	}
+
+	var first interface{}
+	pages := [][]byte{}
+	merged := []interface{}{}
+	previous := ""
+	current := in
+	current.vars = map[string]string{}
+	for k, v := range in.vars {
+		current.vars[k] = v
+	}
+
+	for page := 1; ; page++ {
+		if page > maxPages {
+			return pages, fmt.Errorf("pagination exceeded the maximum of %d pages", maxPages)
+		}
+
+		data, header, err := current.fetchHypertext(client)
+		if err != nil {
+			return pages, fmt.Errorf("error while fetching page %d: %s", page, err.Error())
+		}
+		pages = append(pages, data)
+
+		var doc interface{}
+		if next != nil || items != nil {
+			err = json.Unmarshal(data, &doc)
+			if err != nil {
+				return pages, fmt.Errorf("could not parse page %d: %s", page, err.Error())
+			}
+		}
+		if page == 1 {
+			first = doc
+		}
+
+		var pageItems []interface{}
+		if items != nil {
+			out, err := runQuery(items, doc)
+			if err != nil {
+				return pages, fmt.Errorf("could not read items of page %d: %s", page, err.Error())
+			}
+			var ok bool
+			if pageItems, ok = out.([]interface{}); out != nil && !ok {
+				return pages, fmt.Errorf("items of page %d are not a list", page)
+			}
+			merged = append(merged, pageItems...)
+		}
+
+		key := ""
+		switch p.Kind {
+		case PaginationComposite, PaginationCursor:
+			out, err := runQuery(next, doc)
+			if err != nil {
+				return pages, fmt.Errorf("could not read next key of page %d: %s", page, err.Error())
+			}
+			if out != nil && out != "" && (items == nil || len(pageItems) > 0) {
+				key, err = cursorKey(out, p.Kind)
+				if err != nil {
+					return pages, err
+				}
+			}
+			if p.Kind == PaginationComposite {
+				current.vars["after_key"] = key
+			} else {
+				current.vars["cursor"] = key
+			}
+		case PaginationOffset:
+			if len(pageItems) >= p.Limit {
+				key = fmt.Sprintf("%d", page*p.Limit)
+			}
+			current.vars["offset"] = key
+		case PaginationLink:
+			key, err = nextLink(header, current.URL)
+			if err != nil {
+				return pages, fmt.Errorf("could not read link header of page %d: %s", page, err.Error())
+			}
+		}
+
+		if key == "" {
+			break
+		}
+		if key == previous {
+			return pages, fmt.Errorf("pagination does not advance, page %d returned the same key as its predecessor: %s", page, key)
+		}
+		previous = key
+
+		err = current.render()
+		if err != nil {
+			return pages, err
+		}
+		if p.Kind == PaginationLink {
+			current.URL = key
+		}
+	}
+
+	if p.Merge == MergeSeparate {
+		return pages, nil
+	}
+
+	result, err := setItems(first, p.Items, merged)
+	if err != nil {
+		return pages, err
+	}
+	data, err := json.Marshal(result)
+	return [][]byte{data}, err
+}
+
+// cursorKey returns the key to be rendered into the follow-up request.
+// Cursors are usually strings passed as is, the 'after_key' of a composite
+// aggregation is passed as JSON.
+func cursorKey(v interface{}, kind string) (string, error) {
+	if s, ok := v.(string); ok && kind == PaginationCursor {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// nextLink returns the absolute URL of the 'Link' header with 'rel=next'
+// or an empty string if there is no such header.
+func nextLink(header http.Header, current string) (string, error) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || strings.ToLower(kv[0]) != "rel" {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], "\"")) {
+					if rel != "next" {
+						continue
+					}
+					base, err := url.Parse(current)
+					if err != nil {
+						return "", err
+					}
+					ref, err := url.Parse(strings.Trim(target, "<>"))
+					if err != nil {
+						return "", err
+					}
+					return base.ResolveReference(ref).String(), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+func compileQuery(q string, variables ...string) (*gojq.Code, error) {
+	query, err := gojq.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query, gojq.WithVariables(variables))
+}
+
+// runQuery returns the last value emitted by the query.
+func runQuery(code *gojq.Code, input interface{}, values ...interface{}) (interface{}, error) {
+	var out interface{}
+	iter := code.Run(input, values...)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		out = v
+	}
+	return out, nil
+}
+
+// setItems replaces the value at the path selected by 'selector' in 'doc'.
+func setItems(doc interface{}, selector string, items []interface{}) (interface{}, error) {
+	code, err := compileQuery(fmt.Sprintf("(%s) = $items", selector), "$items")
+	if err != nil {
+		return nil, fmt.Errorf("could not compile pagination selector 'items': %s", err.Error())
+	}
+	out, err := runQuery(code, doc, items)
+	if err != nil {
+		return nil, fmt.Errorf("could not merge items into %s: %s", selector, err.Error())
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package inputreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFetchPaginatedComposite(t *testing.T) {
+	pages := map[string]string{
+		"":             `{"aggregations":{"by_time":{"after_key":{"time":1},"buckets":[{"key":{"time":0}},{"key":{"time":1}}]}}}`,
+		`{"time":1}`:   `{"aggregations":{"by_time":{"after_key":{"time":3},"buckets":[{"key":{"time":2}},{"key":{"time":3}}]}}}`,
+		`{"time":3}`:   `{"aggregations":{"by_time":{"buckets":[]}}}`,
+		`{"time":999}`: `{}`,
+	}
+	requests := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		b, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			After json.RawMessage `json:"after"`
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("request body '%s' is not valid JSON: %s", string(b), err)
+		}
+		page, ok := pages[string(body.After)]
+		if !ok {
+			t.Errorf("unexpected after key '%s'", string(body.After))
+		}
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	c := InputConfig{
+		URL:    ts.URL,
+		Method: http.MethodPost,
+		Body:   `{ {{with .after_key}}"after": {{.}}{{end}} }`,
+		Pagination: PaginationConfig{
+			Kind:  PaginationComposite,
+			Next:  ".aggregations.by_time.after_key",
+			Items: ".aggregations.by_time.buckets",
+		},
+	}
+	in, err := NewInput(c, map[string]string{})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	data, err := fetch(in)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	if requests != 3 {
+		t.Errorf("3 requests were expected, %d were issued", requests)
+	}
+
+	var got, expected interface{}
+	json.Unmarshal(data, &got)
+	json.Unmarshal([]byte(`{"aggregations":{"by_time":{"after_key":{"time":1},"buckets":[{"key":{"time":0}},{"key":{"time":1}},{"key":{"time":2}},{"key":{"time":3}}]}}}`), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("merged result is not as expected: %s", string(data))
+	}
+}
+
+func TestFetchPaginated(t *testing.T) {
+	// the stand-in serves the items 0 to 4 in pages of two items
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			offset, _ = strconv.Atoi(strings.TrimPrefix(c, "c"))
+		}
+		items := []int{}
+		for i := offset; i < offset+2 && i < 5; i++ {
+			items = append(items, i)
+		}
+		resp := map[string]interface{}{"items": items}
+		if offset+2 < 5 {
+			resp["next"] = fmt.Sprintf("c%d", offset+2)
+			w.Header().Add("Link", fmt.Sprintf(`</?offset=%d>; rel="next", </?offset=4>; rel="last"`, offset+2))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		p           PaginationConfig
+		errExpected bool
+		pages       []string
+	}{
+		{
+			name:  "link",
+			url:   ts.URL,
+			p:     PaginationConfig{Kind: PaginationLink, Items: ".items"},
+			pages: []string{`{"items":[0,1,2,3,4],"next":"c2"}`},
+		},
+		{
+			name:  "cursor",
+			url:   ts.URL + "/?cursor={{.cursor}}",
+			p:     PaginationConfig{Kind: PaginationCursor, Next: ".next", Items: ".items"},
+			pages: []string{`{"items":[0,1,2,3,4],"next":"c2"}`},
+		},
+		{
+			name:  "offset",
+			url:   ts.URL + "/?offset={{.offset}}&limit={{.limit}}",
+			p:     PaginationConfig{Kind: PaginationOffset, Limit: 2, Items: ".items"},
+			pages: []string{`{"items":[0,1,2,3,4],"next":"c2"}`},
+		},
+		{
+			name: "cursor_separate",
+			url:  ts.URL + "/?cursor={{.cursor}}",
+			p:    PaginationConfig{Kind: PaginationCursor, Next: ".next", Merge: MergeSeparate},
+			pages: []string{
+				`{"items":[0,1],"next":"c2"}`,
+				`{"items":[2,3],"next":"c4"}`,
+				`{"items":[4]}`,
+			},
+		},
+		{
+			name:        "max_pages_exceeded",
+			url:         ts.URL,
+			p:           PaginationConfig{Kind: PaginationLink, Items: ".items", MaxPages: 2},
+			errExpected: true,
+		},
+		{
+			name:        "not_advancing",
+			url:         ts.URL,
+			p:           PaginationConfig{Kind: PaginationCursor, Next: ".next", Items: ".items"},
+			errExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in, err := NewInput(InputConfig{URL: test.url, Pagination: test.p}, map[string]string{})
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			pages, err := in.FetchPages()
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if test.errExpected {
+				return
+			}
+			if len(pages) != len(test.pages) {
+				t.Fatalf("%d pages were expected, got %d", len(test.pages), len(pages))
+			}
+			for i, page := range pages {
+				var got, expected interface{}
+				json.Unmarshal(page, &got)
+				json.Unmarshal([]byte(test.pages[i]), &expected)
+				if !reflect.DeepEqual(got, expected) {
+					t.Errorf("page %d is not as expected: %s", i, string(page))
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -14,33 +15,80 @@ import (
 	"github.com/mitchellh/go-homedir"
 )
 
-func readFile(path string) ([]byte, error) {
+func readFile(ctx context.Context, path string) ([]byte, error) {
 	path, err := homedir.Expand(path)
 	if err != nil {
 		errOut := fmt.Errorf("error while expanding config file path %s: %s", path, err)
-		return []byte{}, errOut
+		return []byte{}, permanent(errOut)
 	}
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		err = fmt.Errorf("file %s does not exist", path)
-		return []byte{}, err
+		return []byte{}, permanent(err)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := ioutil.ReadFile(path)
+		done <- result{data, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return []byte{}, fmt.Errorf("error while reading %s: %s", path, ctx.Err().Error())
+	case r := <-done:
+		if r.err != nil {
+			return r.data, fmt.Errorf("error while reading %s: %s", path, r.err.Error())
+		}
+		return r.data, nil
 	}
+}
 
-	data, err := ioutil.ReadFile(path)
+// openFile opens the file at path which has to be closed by the caller.
+func openFile(path string) (io.ReadCloser, error) {
+	path, err := homedir.Expand(path)
 	if err != nil {
-		err = fmt.Errorf("error while reading %s: %s", path, err.Error())
-		return data, err
+		errOut := fmt.Errorf("error while expanding config file path %s: %s", path, err)
+		return nil, permanent(errOut)
 	}
 
-	return data, err
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("file %s does not exist", path)
+		return nil, permanent(err)
+	} else if err != nil {
+		return nil, fmt.Errorf("error while reading %s: %s", path, err.Error())
+	}
+	return f, nil
 }
 
-func readHypertext(url, body, method string, headers map[string]string) ([]byte, int, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+func readHypertext(ctx context.Context, client *http.Client, url, body, method string, headers map[string]string) ([]byte, int, http.Header, error) {
+	r, status, header, err := openHypertext(ctx, client, url, body, method, headers)
+	if err != nil {
+		return []byte{}, status, header, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		err = fmt.Errorf("error while reading body of %s: %s", url, err.Error())
+		return data, status, header, err
+	}
+
+	return data, status, header, nil
+}
+
+// openHypertext sends the request and returns the body of the response
+// which has to be closed by the caller.
+func openHypertext(ctx context.Context, client *http.Client, url, body, method string, headers map[string]string) (io.ReadCloser, int, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBufferString(body))
 	if err != nil {
 		err = fmt.Errorf("error while creating request: %s", err.Error())
-		return []byte{}, 0, err
+		return nil, 0, nil, permanent(err)
 	}
 	for k, v := range headers {
 		req.Header.Add(k, v)
@@ -49,24 +97,35 @@ func readHypertext(url, body, method string, headers map[string]string) ([]byte,
 	resp, err := client.Do(req)
 	if err != nil {
 		err = fmt.Errorf("error while fetching from %s: %s", url, err.Error())
-		return []byte{}, 0, err
+		return nil, 0, nil, err
 	}
-	defer resp.Body.Close()
 
-	data, err := ioutil.ReadAll(resp.Body)
+	return resp.Body, resp.StatusCode, resp.Header, nil
+}
+
+func readS3(ctx context.Context, bucket, object string) ([]byte, error) {
+	r, err := openS3(ctx, bucket, object)
 	if err != nil {
-		err = fmt.Errorf("error while reading body of %s: %s", url, err.Error())
-		return data, resp.StatusCode, err
+		return []byte{}, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		err = fmt.Errorf("error while reading body of %s from %s: %s", object, bucket, err.Error())
+		return data, err
 	}
 
-	return data, resp.StatusCode, nil
+	return data, nil
 }
 
-func readS3(bucket, object string) ([]byte, error) {
-	awscfg, err := config.LoadDefaultConfig(context.TODO())
+// openS3 returns the body of the object which has to be closed by the
+// caller.
+func openS3(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
+	awscfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		err = fmt.Errorf("error while creating s3 client to read %s from %s: %s", object, bucket, err.Error())
-		return []byte{}, err
+		return nil, err
 	}
 
 	s3Client := s3.NewFromConfig(awscfg)
@@ -75,17 +134,11 @@ func readS3(bucket, object string) ([]byte, error) {
 		Key:    aws.String(object),
 	}
 
-	result, err := s3Client.GetObject(context.TODO(), input)
+	result, err := s3Client.GetObject(ctx, input)
 	if err != nil {
 		err = fmt.Errorf("error while reading object %s from %s: %s", object, bucket, err.Error())
-		return []byte{}, err
-	}
-	defer result.Body.Close()
-	data, err := ioutil.ReadAll(result.Body)
-	if err != nil {
-		err = fmt.Errorf("error while reading body of %s from %s: %s", object, bucket, err.Error())
-		return data, err
+		return nil, err
 	}
 
-	return data, nil
+	return result.Body, nil
 }
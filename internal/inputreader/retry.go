@@ -0,0 +1,143 @@
+package inputreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const defaultBackoff = time.Second
+
+// permanentError marks errors which are not worth another attempt.
+type permanentError struct {
+	error
+}
+
+func permanent(err error) error {
+	return permanentError{err}
+}
+
+// retry calls read until it succeeds or the retries configured are exhausted.
+// Each call gets its own context which is canceled after the timeout
+// configured. Between the attempts retry waits for an exponentially growing
+// backoff period. Every attempt is reported.
+func (in Input) retry(target string, read func(ctx context.Context) error) error {
+	_, err := in.retryOpen(target, func(ctx context.Context) (io.ReadCloser, error) {
+		return nil, read(ctx)
+	})
+	return err
+}
+
+// retryOpen works like retry but keeps the context of the successful attempt
+// until the reader returned by open is closed. The timeout configured limits
+// the time until open returns and, once the reader is returned, the time
+// each read waits for data, e.g. a slow but steadily progressing stream is
+// not canceled no matter how long reading it takes as a whole.
+func (in Input) retryOpen(target string, open func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	attempts := in.Retries + 1
+	backoff := in.Backoff
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			wait := backoff << uint(attempt-2)
+			in.info(fmt.Sprintf("Waiting %s before attempt %d of %d to read %s", wait, attempt, attempts, target))
+			time.Sleep(wait)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := in.deadline(cancel)
+		var r io.ReadCloser
+		r, err = open(ctx)
+		stop()
+		if err == nil && ctx.Err() != nil {
+			err = fmt.Errorf("no response within %s", in.Timeout)
+			if r != nil {
+				r.Close()
+			}
+		}
+
+		if err == nil {
+			in.info(fmt.Sprintf("Attempt %d of %d to read %s succeeded", attempt, attempts, target))
+			if r == nil {
+				cancel()
+				return nil, nil
+			}
+			return &contextReader{ctx: ctx, cancel: cancel, r: r, timeout: in.Timeout}, nil
+		}
+		cancel()
+		in.info(fmt.Sprintf("Attempt %d of %d to read %s failed: %s", attempt, attempts, target, err.Error()))
+
+		var p permanentError
+		if errors.As(err, &p) {
+			return nil, p.error
+		}
+	}
+	return nil, err
+}
+
+// contextReader cancels its context if a read does not return within the
+// timeout, which aborts reading, and when it is closed.
+type contextReader struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	r       io.ReadCloser
+	timeout time.Duration
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	var stop func() bool
+	if c.timeout > 0 {
+		stop = time.AfterFunc(c.timeout, c.cancel).Stop
+	}
+	n, err := c.r.Read(p)
+	if stop != nil && !stop() {
+		err = fmt.Errorf("no data received within %s", c.timeout)
+	}
+	return n, err
+}
+
+func (c *contextReader) Close() error {
+	defer c.cancel()
+	return c.r.Close()
+}
+
+// deadline calls cancel once the timeout passed, unless the function returned
+// is called before.
+func (in Input) deadline(cancel context.CancelFunc) func() {
+	if in.Timeout <= 0 {
+		return func() {}
+	}
+	t := time.AfterFunc(in.Timeout, cancel)
+	return func() { t.Stop() }
+}
+
+func (in Input) context() (context.Context, context.CancelFunc) {
+	if in.Timeout > 0 {
+		return context.WithTimeout(context.Background(), in.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func (in Input) retryOnStatus(status int) bool {
+	for _, s := range in.RetryOnStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (in Input) info(msg string) {
+	if in.Info != nil {
+		in.Info(Redact(msg))
+	}
+}
@@ -0,0 +1,82 @@
+package inputreader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRetry(t *testing.T) {
+	// the counter is shared with the handler goroutines of the stand-in
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		c           InputConfig
+		errExpected bool
+		requests    int32
+	}{
+		{
+			name:        "no_retries",
+			c:           InputConfig{URL: ts.URL, HTTPExpectStatus: 200},
+			errExpected: true,
+			requests:    1,
+		},
+		{
+			name:        "status_not_retried",
+			c:           InputConfig{URL: ts.URL, HTTPExpectStatus: 200, Retries: 3, Backoff: time.Millisecond},
+			errExpected: true,
+			requests:    1,
+		},
+		{
+			name:        "retries_exhausted",
+			c:           InputConfig{URL: ts.URL, Retries: 1, Backoff: time.Millisecond, Timeout: 20 * time.Millisecond, RetryOnStatus: []int{503}},
+			errExpected: true,
+			requests:    2,
+		},
+		{
+			name:        "third_attempt_succeeds",
+			c:           InputConfig{URL: ts.URL, Retries: 3, Backoff: time.Millisecond, Timeout: 20 * time.Millisecond, RetryOnStatus: []int{503}},
+			errExpected: false,
+			requests:    3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			in, err := NewInput(test.c, map[string]string{})
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			reported := []string{}
+			in.Info = func(msg string) { reported = append(reported, msg) }
+
+			_, err = fetch(in)
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if issued := atomic.LoadInt32(&requests); issued != test.requests {
+				t.Errorf("%d requests were expected, %d were issued", test.requests, issued)
+			}
+			if !test.errExpected && !strings.Contains(reported[len(reported)-1], "succeeded") {
+				t.Errorf("last attempt was expected to be reported as succeeded: %v", reported)
+			}
+		})
+	}
+}
@@ -1,20 +1,88 @@
 package inputreader
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/tj/go-naturaldate"
 )
 
+const redacted = "[REDACTED]"
+
+var (
+	secretsMu sync.Mutex
+	secrets   = map[string]bool{}
+)
+
 func getTemplateFuncMap() template.FuncMap {
 	funcMap := template.FuncMap{
 		"unixMilliTimestamp": unixMilliTimestamp,
 		"unixTimestamp":      unixTimestamp,
+		"env":                env,
+		"file":               file,
+		"plainEnv":           plainEnv,
+		"plainFile":          plainFile,
+		"ssm":                ssmParameter,
+		"secret":             secretValue,
 	}
 	return funcMap
 }
 
+// RenderTemplate renders the template 't' using the vars 'v'. The hint is
+// used to point to the template in error messages.
+func RenderTemplate(t, hint string, v map[string]string) (string, error) {
+	templ, err := template.New("template").Funcs(getTemplateFuncMap()).Parse(t)
+	if err != nil {
+		return "", fmt.Errorf("could not parse %s template: %s", hint, err.Error())
+	}
+	b := &strings.Builder{}
+	err = templ.Execute(b, v)
+	if err != nil {
+		return "", fmt.Errorf("could not render %s template: %s", hint, err.Error())
+	}
+	return b.String(), nil
+}
+
+// Redact replaces all values resolved by the template functions 'env',
+// 'file', 'ssm' and 'secret' so far in the string passed.
+func Redact(in string) string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	// longer secrets are replaced first in case they contain shorter ones
+	sorted := []string{}
+	for s := range secrets {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, s := range sorted {
+		in = strings.ReplaceAll(in, s, redacted)
+	}
+	return in
+}
+
+func remember(secret string) string {
+	if secret != "" {
+		secretsMu.Lock()
+		secrets[secret] = true
+		secretsMu.Unlock()
+	}
+	return secret
+}
+
 func unixMilliTimestamp(in string) (int64, error) {
 	t, err := timestamp(in)
 	return t.UnixMilli(), err
@@ -33,3 +101,117 @@ func timestamp(in string) (time.Time, error) {
 
 	return naturaldate.Parse(in, time.Now())
 }
+
+func env(name string) (string, error) {
+	v, err := plainEnv(name)
+	return remember(v), err
+}
+
+func file(path string) (string, error) {
+	v, err := plainFile(path)
+	return remember(v), err
+}
+
+// plainEnv works like env but the value is not redacted in the output.
+func plainEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+// plainFile works like file but the content is not redacted in the output.
+func plainFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error while reading %s: %s", path, err.Error())
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ssmParameter returns the decrypted value of a parameter stored in the AWS
+// SSM Parameter Store.
+func ssmParameter(name string) (string, error) {
+	awscfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("error while creating ssm client to read %s: %s", name, err.Error())
+	}
+
+	opts := []func(*ssm.Options){}
+	if endpoint := awsEndpoint("SSM"); endpoint != "" {
+		opts = append(opts, ssm.WithEndpointResolver(ssm.EndpointResolverFromURL(endpoint)))
+	}
+	client := ssm.NewFromConfig(awscfg, opts...)
+
+	out, err := client.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error while reading parameter %s: %s", name, err.Error())
+	}
+	if out.Parameter == nil || out.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+	return remember(*out.Parameter.Value), nil
+}
+
+// secretValue returns the value of a secret stored in the AWS Secrets
+// Manager. If a key is passed the secret is expected to be a JSON object,
+// the value of the key is returned.
+func secretValue(id string, key ...string) (string, error) {
+	if len(key) > 1 {
+		return "", fmt.Errorf("secret %s: at most one key can be passed", id)
+	}
+
+	awscfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("error while creating secrets manager client to read %s: %s", id, err.Error())
+	}
+
+	opts := []func(*secretsmanager.Options){}
+	if endpoint := awsEndpoint("SECRETS_MANAGER"); endpoint != "" {
+		opts = append(opts, secretsmanager.WithEndpointResolver(secretsmanager.EndpointResolverFromURL(endpoint)))
+	}
+	client := secretsmanager.NewFromConfig(awscfg, opts...)
+
+	out, err := client.GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error while reading secret %s: %s", id, err.Error())
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", id)
+	}
+	if len(key) == 0 {
+		return remember(*out.SecretString), nil
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal([]byte(*out.SecretString), &fields)
+	if err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %s", id, err.Error())
+	}
+	v, ok := fields[key[0]]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", id, key[0])
+	}
+	s, ok := v.(string)
+	if !ok {
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	return remember(s), nil
+}
+
+// awsEndpoint returns a custom endpoint for the service if configured via
+// the environment variables 'AWS_ENDPOINT_URL_<SERVICE>' or
+// 'AWS_ENDPOINT_URL', for example to use a local stand-in.
+func awsEndpoint(service string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_" + service); endpoint != "" {
+		return endpoint
+	}
+	return os.Getenv("AWS_ENDPOINT_URL")
+}
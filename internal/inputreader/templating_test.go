@@ -0,0 +1,130 @@
+package inputreader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderTemplateSecrets(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_REGION", "eu-central-2")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("TRADUCTIO_TEST_SECRET", "s3cr3t-from-env")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	ioutil.WriteFile(secretFile, []byte("s3cr3t-from-file\n"), 0600)
+
+	// the stand-in serves both, SSM and Secrets Manager
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.GetParameter":
+			if req["Name"] != "/traductio/token" || req["WithDecryption"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ParameterNotFound","message":"not found"}`))
+				return
+			}
+			w.Write([]byte(`{"Parameter":{"Name":"/traductio/token","Type":"SecureString","Value":"s3cr3t-from-ssm"}}`))
+		case "secretsmanager.GetSecretValue":
+			if req["SecretId"] != "traductio" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+				return
+			}
+			w.Write([]byte(`{"Name":"traductio","SecretString":"{\"password\":\"s3cr3t-from-secretsmanager\"}"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ENDPOINT_URL", ts.URL)
+
+	tests := []struct {
+		name        string
+		template    string
+		errExpected bool
+		rendered    string
+	}{
+		{
+			name:     "env",
+			template: `{{env "TRADUCTIO_TEST_SECRET"}}`,
+			rendered: "s3cr3t-from-env",
+		},
+		{
+			name:        "env_unset",
+			template:    `{{env "TRADUCTIO_TEST_UNSET"}}`,
+			errExpected: true,
+		},
+		{
+			name:     "file",
+			template: `{{file "` + secretFile + `"}}`,
+			rendered: "s3cr3t-from-file",
+		},
+		{
+			name:     "ssm",
+			template: `{{ssm "/traductio/token"}}`,
+			rendered: "s3cr3t-from-ssm",
+		},
+		{
+			name:        "ssm_not_found",
+			template:    `{{ssm "/traductio/unknown"}}`,
+			errExpected: true,
+		},
+		{
+			name:     "secret_key",
+			template: `{{secret "traductio" "password"}}`,
+			rendered: "s3cr3t-from-secretsmanager",
+		},
+		{
+			name:     "secret",
+			template: `{{secret "traductio"}}`,
+			rendered: `{"password":"s3cr3t-from-secretsmanager"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := RenderTemplate("Bearer "+test.template, test.name, map[string]string{})
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if test.errExpected {
+				return
+			}
+			if rendered != "Bearer "+test.rendered {
+				t.Errorf("'Bearer %s' was expected, got '%s'", test.rendered, rendered)
+			}
+			if r := Redact(rendered); r != "Bearer "+redacted {
+				t.Errorf("secret was expected to be redacted, got '%s'", r)
+			}
+		})
+	}
+}
+
+func TestRenderTemplatePlain(t *testing.T) {
+	t.Setenv("TRADUCTIO_TEST_STAGE", "prod")
+	plainFile := filepath.Join(t.TempDir(), "replicas")
+	ioutil.WriteFile(plainFile, []byte("1\n"), 0600)
+
+	rendered, err := RenderTemplate(`https://{{plainEnv "TRADUCTIO_TEST_STAGE"}}.example.com/v1?replicas={{plainFile "`+plainFile+`"}}`, "url", map[string]string{})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	expected := "https://prod.example.com/v1?replicas=1"
+	if rendered != expected {
+		t.Errorf("'%s' was expected, got '%s'", expected, rendered)
+	}
+	if r := Redact("Attempt 1 of 1 to read " + rendered + " succeeded"); r != "Attempt 1 of 1 to read "+expected+" succeeded" {
+		t.Errorf("values of 'plainEnv' and 'plainFile' are not to be redacted, got '%s'", r)
+	}
+}
@@ -0,0 +1,165 @@
+package sink
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result keeps the outcome of writing to a single sink.
+type Result struct {
+	Name     string
+	Kind     string
+	Policy   string
+	Points   int
+	Duration time.Duration
+	Err      error
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("sink '%s' (%s, %s) failed after %s: %s", r.Name, r.Kind, r.Policy, r.Duration.Round(time.Millisecond), r.Err.Error())
+	}
+	return fmt.Sprintf("sink '%s' (%s, %s) saved %d data points in %s", r.Name, r.Kind, r.Policy, r.Points, r.Duration.Round(time.Millisecond))
+}
+
+// FanOut writes points to several sinks concurrently. Sinks with the policy
+// 'best_effort' which fail are reported but skipped afterwards, failing
+// sinks with the policy 'required' fail the write.
+type FanOut struct {
+	outputs []*output
+}
+
+type output struct {
+	sink   Sink
+	result Result
+}
+
+// NewFanOut sets up all sinks configured. An error is returned if a sink
+// with the policy 'required' cannot be set up.
+func NewFanOut(configs []Config) (*FanOut, error) {
+	f := &FanOut{}
+	if len(configs) < 1 {
+		return f, fmt.Errorf("no output configured")
+	}
+
+	names := map[string]bool{}
+	for i, c := range configs {
+		o := &output{
+			result: Result{Name: c.Name, Kind: c.Kind, Policy: c.Policy},
+		}
+		if o.result.Name == "" {
+			o.result.Name = fmt.Sprintf("%s[%d]", c.Kind, i)
+		}
+		if o.result.Policy == "" {
+			o.result.Policy = PolicyRequired
+		}
+		if names[o.result.Name] {
+			f.Close()
+			return f, fmt.Errorf("output name '%s' is not unique", o.result.Name)
+		}
+		names[o.result.Name] = true
+
+		if errs, err := c.Validate(); err != nil {
+			f.Close()
+			return f, fmt.Errorf("%s of output '%s': %s", err.Error(), o.result.Name, strings.Join(errs, " "))
+		}
+
+		o.sink, o.result.Err = New(c)
+		if o.result.Err != nil {
+			o.sink = nil
+			if o.result.Policy == PolicyRequired {
+				f.Close()
+				return f, fmt.Errorf("could not set up output '%s': %s", o.result.Name, o.result.Err.Error())
+			}
+		}
+		f.outputs = append(f.outputs, o)
+	}
+	return f, nil
+}
+
+// Prepare passes the structure of the points to all sinks implementing the
+// Preparer interface. An error is returned if preparing a sink with the
+// policy 'required' failed.
+func (f *FanOut) Prepare(tags, values []string) error {
+	tags, values = unique(tags), unique(values)
+	for _, o := range f.outputs {
+		p, ok := o.sink.(Preparer)
+		if o.result.Err != nil || !ok {
+			continue
+		}
+		o.result.Err = p.Prepare(tags, values)
+		if o.result.Err != nil && o.result.Policy == PolicyRequired {
+			return fmt.Errorf("could not prepare output '%s': %s", o.result.Name, o.result.Err.Error())
+		}
+	}
+	return nil
+}
+
+// Write writes the points to all sinks concurrently. An error is returned if
+// writing to at least one sink with the policy 'required' failed.
+func (f *FanOut) Write(points []Point) error {
+	wg := sync.WaitGroup{}
+	for _, o := range f.outputs {
+		if o.result.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(o *output) {
+			defer wg.Done()
+			start := time.Now()
+			err := o.sink.Write(points)
+			o.result.Duration += time.Since(start)
+			if err != nil {
+				o.result.Err = err
+				return
+			}
+			o.result.Points += len(points)
+		}(o)
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for _, o := range f.outputs {
+		if o.result.Err != nil && o.result.Policy == PolicyRequired {
+			failed = append(failed, fmt.Sprintf("%s: %s", o.result.Name, o.result.Err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("writing to required outputs failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Results returns the outcome of each sink.
+func (f *FanOut) Results() []Result {
+	results := []Result{}
+	for _, o := range f.outputs {
+		results = append(results, o.result)
+	}
+	return results
+}
+
+// Close closes all sinks.
+func (f *FanOut) Close() {
+	for _, o := range f.outputs {
+		if o.sink != nil {
+			o.sink.Close()
+		}
+	}
+}
+
+func unique(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memory struct {
+	mu     *sync.Mutex
+	points *[]Point
+	fail   bool
+}
+
+func (m memory) Write(points []Point) error {
+	if m.fail {
+		return fmt.Errorf("sink is broken")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.points = append(*m.points, points...)
+	return nil
+}
+
+func (m memory) Close() {}
+
+func init() {
+	Register("memory", func(c map[string]string) (Sink, error) {
+		if c["setup"] == "fail" {
+			return nil, fmt.Errorf("sink cannot be set up")
+		}
+		return memory{mu: &sync.Mutex{}, points: &[]Point{}, fail: c["write"] == "fail"}, nil
+	})
+}
+
+func TestFanOut(t *testing.T) {
+	points := []Point{
+		{Timestamp: time.Unix(0, 0), Values: map[string]float64{"count": 1}},
+		{Timestamp: time.Unix(1, 0), Values: map[string]float64{"count": 2}},
+	}
+
+	tests := []struct {
+		name             string
+		configs          []Config
+		setupErrExpected bool
+		writeErrExpected bool
+		failed           int
+	}{
+		{
+			name:             "no_outputs",
+			configs:          []Config{},
+			setupErrExpected: true,
+		},
+		{
+			name: "all_succeed",
+			configs: []Config{
+				{Kind: "memory"},
+				{Kind: "memory", Policy: PolicyBestEffort},
+			},
+		},
+		{
+			name: "best_effort_write_fails",
+			configs: []Config{
+				{Kind: "memory"},
+				{Kind: "memory", Policy: PolicyBestEffort, Connection: map[string]string{"write": "fail"}},
+			},
+			failed: 1,
+		},
+		{
+			name: "best_effort_setup_fails",
+			configs: []Config{
+				{Kind: "memory"},
+				{Kind: "memory", Policy: PolicyBestEffort, Connection: map[string]string{"setup": "fail"}},
+			},
+			failed: 1,
+		},
+		{
+			name: "required_write_fails",
+			configs: []Config{
+				{Kind: "memory", Policy: PolicyRequired, Connection: map[string]string{"write": "fail"}},
+				{Kind: "memory", Policy: PolicyBestEffort},
+			},
+			writeErrExpected: true,
+			failed:           1,
+		},
+		{
+			name: "required_setup_fails",
+			configs: []Config{
+				{Kind: "memory", Connection: map[string]string{"setup": "fail"}},
+			},
+			setupErrExpected: true,
+		},
+		{
+			name: "duplicate_names",
+			configs: []Config{
+				{Name: "local", Kind: "memory"},
+				{Name: "local", Kind: "memory"},
+			},
+			setupErrExpected: true,
+		},
+		{
+			name: "invalid_policy",
+			configs: []Config{
+				{Kind: "memory", Policy: "sometimes"},
+			},
+			setupErrExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFanOut(test.configs)
+			if err == nil && test.setupErrExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.setupErrExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if test.setupErrExpected {
+				return
+			}
+			defer f.Close()
+
+			err = f.Write(points)
+			if err == nil && test.writeErrExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.writeErrExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+
+			results := f.Results()
+			if len(results) != len(test.configs) {
+				t.Fatalf("%d results were expected, got %d", len(test.configs), len(results))
+			}
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+				} else if r.Points != len(points) {
+					t.Errorf("%s: %d points were expected to be saved, got %d", r.Name, len(points), r.Points)
+				}
+			}
+			if failed != test.failed {
+				t.Errorf("%d sinks were expected to fail, %d failed", test.failed, failed)
+			}
+		})
+	}
+}
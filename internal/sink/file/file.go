@@ -0,0 +1,234 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+	"traductio/internal/sink/storage"
+)
+
+func init() {
+	sink.Register("file", setup)
+}
+
+const (
+	FormatCSV          = "csv"
+	FormatJSONLines    = "jsonl"
+	FormatLineProtocol = "lineprotocol"
+
+	ModeOverwrite = "overwrite"
+	ModeAppend    = "append"
+)
+
+// File writes the points to a local file or to an object on S3 if the path
+// is an URL with the scheme 's3'.
+type File struct {
+	Path      string
+	Format    string
+	Mode      string
+	Gzip      bool
+	Series    string
+	Delimiter rune
+	Precision time.Duration
+
+	// once written in overwrite mode, further writes of the same run are
+	// appended
+	written bool
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	f := &File{
+		Format:    FormatCSV,
+		Mode:      ModeOverwrite,
+		Delimiter: ',',
+		Precision: time.Nanosecond,
+	}
+
+	var found bool
+	var err error
+
+	if f.Path, found = config["path"]; !found || f.Path == "" {
+		return f, fmt.Errorf("sink requires field 'path' to be set")
+	}
+	if format, found := config["format"]; found && format != "" {
+		f.Format = format
+	}
+	switch f.Format {
+	case FormatCSV, FormatJSONLines:
+	case FormatLineProtocol:
+		if config["series"] == "" {
+			return f, fmt.Errorf("sink with format '%s' requires field 'series' to be set", f.Format)
+		}
+	default:
+		return f, fmt.Errorf("sink field 'format' must be one of '%s', '%s' or '%s'", FormatCSV, FormatJSONLines, FormatLineProtocol)
+	}
+	f.Series = config["series"]
+	if mode, found := config["mode"]; found && mode != "" {
+		f.Mode = mode
+	}
+	if f.Mode != ModeOverwrite && f.Mode != ModeAppend {
+		return f, fmt.Errorf("sink field 'mode' must be either '%s' or '%s'", ModeOverwrite, ModeAppend)
+	}
+	if gz, found := config["gzip"]; found && gz != "" {
+		if f.Gzip, err = strconv.ParseBool(gz); err != nil {
+			return f, fmt.Errorf("sink field 'gzip' must be either 'true' or 'false'")
+		}
+	}
+	if delimiter, found := config["delimiter"]; found && delimiter != "" {
+		if len([]rune(delimiter)) != 1 {
+			return f, fmt.Errorf("sink field 'delimiter' must be exactly one character")
+		}
+		f.Delimiter = []rune(delimiter)[0]
+	}
+	if precision, found := config["precision"]; found && precision != "" {
+		if f.Precision, found = sink.Precisions[precision]; !found {
+			return f, fmt.Errorf("sink field 'precision' must be one of 's', 'ms', 'us' or 'ns'")
+		}
+	}
+
+	return f, nil
+}
+
+func (f *File) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	var err error
+	var existing []byte
+	appending := f.Mode == ModeAppend || f.written
+	if appending && f.Format == FormatCSV {
+		existing, err = storage.Read(f.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := f.encode(points, existing)
+	if err != nil {
+		return err
+	}
+	if f.Gzip {
+		data, err = compress(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// concatenated gzip streams are valid gzip streams as well
+	err = storage.Write(f.Path, data, appending)
+	if err != nil {
+		return err
+	}
+
+	f.written = true
+	return nil
+}
+
+// encode renders the points in the format configured. CSV files get a header
+// unless they are appended to, in that case the header of the existing file
+// is used.
+func (f *File) encode(points []sink.Point, existing []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	switch f.Format {
+	case FormatCSV:
+		header, err := f.csvHeader(existing)
+		if err != nil {
+			return []byte{}, err
+		}
+		writer := csv.NewWriter(&out)
+		writer.Comma = f.Delimiter
+		if header == nil {
+			header = sink.CSVHeader(points)
+			writer.Write(header)
+		} else if missing := missingColumns(header, sink.CSVHeader(points)); len(missing) > 0 {
+			return []byte{}, fmt.Errorf("cannot append to %s, columns %s are not part of its header", f.Path, strings.Join(missing, ", "))
+		}
+		for _, p := range points {
+			writer.Write(sink.CSVRecord(p, header))
+		}
+		writer.Flush()
+		return out.Bytes(), writer.Error()
+	case FormatJSONLines:
+		encoder := json.NewEncoder(&out)
+		for _, p := range points {
+			line := struct {
+				Time   string             `json:"time"`
+				Series string             `json:"series,omitempty"`
+				Tags   map[string]string  `json:"tags"`
+				Values map[string]float64 `json:"values"`
+			}{
+				Time:   p.Timestamp.Format(time.RFC3339Nano),
+				Series: f.Series,
+				Tags:   p.Tags,
+				Values: p.Values,
+			}
+			if err := encoder.Encode(line); err != nil {
+				return []byte{}, fmt.Errorf("could not encode point as JSON: %s", err.Error())
+			}
+		}
+		return out.Bytes(), nil
+	default:
+		return sink.PointsAsLineProtocol(f.Series, points, f.Precision)
+	}
+}
+
+// csvHeader returns the header of the existing data or nil if there is no
+// data yet.
+func (f *File) csvHeader(existing []byte) ([]string, error) {
+	if len(existing) == 0 {
+		return nil, nil
+	}
+	var r io.Reader = bytes.NewReader(existing)
+	if f.Gzip {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress %s: %s", f.Path, err.Error())
+		}
+		defer gz.Close()
+		r = gz
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = f.Delimiter
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read header of %s: %s", f.Path, err.Error())
+	}
+	return header, nil
+}
+
+func (f *File) Close() {}
+
+func missingColumns(header, columns []string) []string {
+	known := map[string]bool{}
+	for _, h := range header {
+		known[h] = true
+	}
+	out := []string{}
+	for _, c := range columns {
+		if !known[c] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func compress(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	if _, err := gz.Write(data); err != nil {
+		return []byte{}, err
+	}
+	err := gz.Close()
+	return out.Bytes(), err
+}
@@ -0,0 +1,118 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+)
+
+func TestWrite(t *testing.T) {
+	ts := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	first := []sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a", "zone": "x"}, Values: map[string]float64{"hits": 3, "bytes": 1.5}},
+	}
+	second := []sink.Point{
+		{Timestamp: ts.Add(time.Second), Tags: map[string]string{"host": "b"}, Values: map[string]float64{"bytes": 2}},
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		errExpected bool
+		expected    string
+	}{
+		{
+			name:   "csv_overwrite",
+			config: map[string]string{"format": "csv"},
+			expected: "time,host,bytes\n" +
+				"2021-12-01 00:00:01 +0000 UTC,b,2.000000\n",
+		},
+		{
+			name:   "csv_append",
+			config: map[string]string{"format": "csv", "mode": "append"},
+			expected: "time,host,zone,bytes,hits\n" +
+				"2021-12-01 00:00:00 +0000 UTC,a,x,1.500000,3.000000\n" +
+				"2021-12-01 00:00:01 +0000 UTC,b,,2.000000,\n",
+		},
+		{
+			name:   "jsonl_append_gzip",
+			config: map[string]string{"format": "jsonl", "mode": "append", "gzip": "true", "series": "traffic"},
+			expected: `{"time":"2021-12-01T00:00:00Z","series":"traffic","tags":{"host":"a","zone":"x"},"values":{"bytes":1.5,"hits":3}}` + "\n" +
+				`{"time":"2021-12-01T00:00:01Z","series":"traffic","tags":{"host":"b"},"values":{"bytes":2}}` + "\n",
+		},
+		{
+			name:   "lineprotocol_append",
+			config: map[string]string{"format": "lineprotocol", "mode": "append", "series": "traffic", "precision": "s"},
+			expected: "traffic,host=a,zone=x bytes=1.5,hits=3 1638316800\n" +
+				"traffic,host=b bytes=2 1638316801\n",
+		},
+		{
+			name:        "lineprotocol_without_series",
+			config:      map[string]string{"format": "lineprotocol"},
+			errExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out", "points")
+			test.config["path"] = path
+
+			// the file is written by two separate runs
+			for _, points := range [][]sink.Point{first, second} {
+				s, err := setup(test.config)
+				if err == nil && test.errExpected {
+					t.Fatalf("error was expected, error was <nil>")
+				} else if err != nil && !test.errExpected {
+					t.Fatalf("no error was expected, error was '%s'", err)
+				}
+				if test.errExpected {
+					return
+				}
+				if err = s.Write(points); err != nil {
+					t.Fatalf("no error was expected, error was '%s'", err)
+				}
+				s.Close()
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if test.config["gzip"] == "true" {
+				gz, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("no error was expected, error was '%s'", err)
+				}
+				data, _ = ioutil.ReadAll(gz)
+			}
+			if string(data) != test.expected {
+				t.Errorf("content is not as expected:\n%s\n%s", string(data), test.expected)
+			}
+		})
+	}
+}
+
+func TestWriteAppendUnknownColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.csv")
+	err := ioutil.WriteFile(path, []byte("time,host,bytes\n"), 0644)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	s, _ := setup(map[string]string{"path": path, "mode": "append"})
+	err = s.Write([]sink.Point{
+		{Timestamp: time.Now(), Tags: map[string]string{"zone": "x"}, Values: map[string]float64{"bytes": 1}},
+	})
+	if err == nil {
+		t.Errorf("error was expected, error was <nil>")
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(len("time,host,bytes\n")) {
+		t.Errorf("file was modified")
+	}
+}
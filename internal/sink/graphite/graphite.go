@@ -0,0 +1,284 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+)
+
+func init() {
+	sink.Register("graphite", setup)
+}
+
+const (
+	ProtocolPlaintext = "plaintext"
+	ProtocolPickle    = "pickle"
+
+	defaultBatchSize = 500
+	defaultRetries   = 3
+	defaultBackoff   = time.Second
+	defaultTimeout   = 10 * time.Second
+	defaultPath      = "{series}.{tags}.{key}"
+	defaultTagged    = "{series}.{key}"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\{(series|key|tags|tags\.[^}]+)\}`)
+	invalidNode = regexp.MustCompile(`[^A-Za-z0-9_\-:]`)
+	invalidTag  = regexp.MustCompile(`[\s;!^=~]`)
+)
+
+// Graphite sends points to Carbon using the plaintext or the pickle protocol.
+// Each value of a point is sent as metric, its path is built from the path
+// template which supports the placeholders '{series}', '{key}' (the value
+// key), '{tags.<name>}' (the value of a tag) and '{tags}' (the values of all
+// tags sorted by the tag names). With 'tagged' set the tags are appended as
+// ';<name>=<value>' instead.
+type Graphite struct {
+	Addr      string
+	Protocol  string
+	Series    string
+	Path      string
+	Tagged    bool
+	BatchSize int
+	Retries   int
+	Backoff   time.Duration
+	Timeout   time.Duration
+
+	conn net.Conn
+}
+
+type metric struct {
+	path      string
+	value     float64
+	timestamp int64
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	g := &Graphite{
+		Protocol:  ProtocolPlaintext,
+		BatchSize: defaultBatchSize,
+		Retries:   defaultRetries,
+		Backoff:   defaultBackoff,
+		Timeout:   defaultTimeout,
+	}
+
+	var found bool
+	var err error
+
+	if g.Addr, found = config["addr"]; !found || g.Addr == "" {
+		return g, fmt.Errorf("sink requires field 'addr' to be set")
+	}
+	if g.Series, found = config["series"]; !found || g.Series == "" {
+		return g, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	if protocol, found := config["protocol"]; found && protocol != "" {
+		g.Protocol = protocol
+	}
+	if g.Protocol != ProtocolPlaintext && g.Protocol != ProtocolPickle {
+		return g, fmt.Errorf("sink field 'protocol' must be either '%s' or '%s'", ProtocolPlaintext, ProtocolPickle)
+	}
+	if tagged, found := config["tagged"]; found && tagged != "" {
+		if g.Tagged, err = strconv.ParseBool(tagged); err != nil {
+			return g, fmt.Errorf("sink field 'tagged' must be either 'true' or 'false'")
+		}
+	}
+	g.Path = defaultPath
+	if g.Tagged {
+		g.Path = defaultTagged
+	}
+	if path, found := config["path"]; found && path != "" {
+		g.Path = path
+	}
+	if batchSize, found := config["batch_size"]; found && batchSize != "" {
+		if g.BatchSize, err = strconv.Atoi(batchSize); err != nil || g.BatchSize < 1 {
+			return g, fmt.Errorf("sink field 'batch_size' must be a positive number")
+		}
+	}
+	if retries, found := config["retries"]; found && retries != "" {
+		if g.Retries, err = strconv.Atoi(retries); err != nil || g.Retries < 0 {
+			return g, fmt.Errorf("sink field 'retries' must be a number not below zero")
+		}
+	}
+	if backoff, found := config["backoff"]; found && backoff != "" {
+		if g.Backoff, err = time.ParseDuration(backoff); err != nil || g.Backoff < 0 {
+			return g, fmt.Errorf("sink field 'backoff' must be a duration not below zero")
+		}
+	}
+	if timeout, found := config["timeout"]; found && timeout != "" {
+		if g.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return g, fmt.Errorf("sink field 'timeout' must be a duration: %s", err.Error())
+		}
+	}
+
+	return g, nil
+}
+
+func (g *Graphite) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	metrics := []metric{}
+	for _, p := range points {
+		keys := []string{}
+		for k := range p.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := p.Values[k]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			metrics = append(metrics, metric{path: g.metricPath(k, p.Tags), value: v, timestamp: p.Timestamp.Unix()})
+		}
+	}
+
+	for i := 0; i < len(metrics); i += g.BatchSize {
+		end := i + g.BatchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		var data []byte
+		if g.Protocol == ProtocolPickle {
+			data = encodePickle(metrics[i:end])
+		} else {
+			data = encodePlaintext(metrics[i:end])
+		}
+		if err := g.send(data); err != nil {
+			return fmt.Errorf("error while sending metrics %d to %d: %s", i+1, end, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// send writes the data to the connection. If this fails the connection is
+// established again and the data is resent up to 'retries' times. Before
+// each retry it waits for the backoff period which doubles with every retry.
+func (g *Graphite) send(data []byte) error {
+	var err error
+	for attempt := 0; attempt <= g.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(g.Backoff << uint(attempt-1))
+		}
+		if g.conn == nil {
+			g.conn, err = net.DialTimeout("tcp", g.Addr, g.Timeout)
+			if err != nil {
+				continue
+			}
+		}
+		g.conn.SetWriteDeadline(time.Now().Add(g.Timeout))
+		if _, err = g.conn.Write(data); err == nil {
+			return nil
+		}
+		g.conn.Close()
+		g.conn = nil
+	}
+	return err
+}
+
+func (g *Graphite) metricPath(key string, tags map[string]string) string {
+	path := placeholder.ReplaceAllStringFunc(g.Path, func(p string) string {
+		name := strings.Trim(p, "{}")
+		switch {
+		case name == "series":
+			nodes := strings.Split(g.Series, ".")
+			for i, n := range nodes {
+				nodes[i] = invalidNode.ReplaceAllString(n, "_")
+			}
+			return strings.Join(nodes, ".")
+		case name == "key":
+			return invalidNode.ReplaceAllString(key, "_")
+		case name == "tags":
+			names := []string{}
+			for k, v := range tags {
+				if v != "" {
+					names = append(names, k)
+				}
+			}
+			sort.Strings(names)
+			nodes := []string{}
+			for _, n := range names {
+				nodes = append(nodes, invalidNode.ReplaceAllString(tags[n], "_"))
+			}
+			return strings.Join(nodes, ".")
+		default:
+			return invalidNode.ReplaceAllString(tags[strings.TrimPrefix(name, "tags.")], "_")
+		}
+	})
+
+	// placeholders without value must not leave empty nodes behind
+	nodes := []string{}
+	for _, n := range strings.Split(path, ".") {
+		if n != "" {
+			nodes = append(nodes, n)
+		}
+	}
+	path = strings.Join(nodes, ".")
+
+	if g.Tagged {
+		names := []string{}
+		for k, v := range tags {
+			if v != "" {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			path += ";" + invalidTag.ReplaceAllString(n, "_") + "=" + invalidTag.ReplaceAllString(tags[n], "_")
+		}
+	}
+
+	return path
+}
+
+func (g *Graphite) Close() {
+	if g.conn != nil {
+		g.conn.Close()
+	}
+}
+
+func encodePlaintext(metrics []metric) []byte {
+	var out bytes.Buffer
+	for _, m := range metrics {
+		fmt.Fprintf(&out, "%s %s %d\n", m.path, strconv.FormatFloat(m.value, 'f', -1, 64), m.timestamp)
+	}
+	return out.Bytes()
+}
+
+// encodePickle encodes the metrics as pickled list of tuples
+// '(path, (timestamp, value))' prefixed with the length of the payload as
+// expected by the Carbon pickle receiver.
+func encodePickle(metrics []metric) []byte {
+	var p bytes.Buffer
+	p.Write([]byte{0x80, 0x02}) // PROTO 2
+	p.WriteByte(']')            // EMPTY_LIST
+	p.WriteByte('(')            // MARK
+	for _, m := range metrics {
+		p.WriteByte('X') // BINUNICODE
+		binary.Write(&p, binary.LittleEndian, uint32(len(m.path)))
+		p.WriteString(m.path)
+		p.WriteByte('J') // BININT
+		binary.Write(&p, binary.LittleEndian, int32(m.timestamp))
+		p.WriteByte('G') // BINFLOAT
+		binary.Write(&p, binary.BigEndian, m.value)
+		p.WriteByte(0x86) // TUPLE2
+		p.WriteByte(0x86) // TUPLE2
+	}
+	p.WriteByte('e') // APPENDS
+	p.WriteByte('.') // STOP
+
+	out := make([]byte, 4, 4+p.Len())
+	binary.BigEndian.PutUint32(out, uint32(p.Len()))
+	return append(out, p.Bytes()...)
+}
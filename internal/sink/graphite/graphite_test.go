@@ -0,0 +1,135 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+)
+
+// receive starts a stand-in for Carbon and returns its address and a channel
+// providing all data received once the sink has closed its connection.
+func receive(t *testing.T) (string, chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- data
+	}()
+	return l.Addr().String(), received
+}
+
+func TestWrite(t *testing.T) {
+	ts := time.Unix(1638316800, 0)
+	points := []sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "web 1", "zone": "eu.west"}, Values: map[string]float64{"hits": 3, "bytes": 1.5}},
+		{Timestamp: ts, Tags: map[string]string{"host": "web2", "zone": ""}, Values: map[string]float64{"hits": 4}},
+	}
+
+	tests := []struct {
+		name     string
+		config   map[string]string
+		expected string
+	}{
+		{
+			name:   "default",
+			config: map[string]string{},
+			expected: "traffic.web_1.eu_west.bytes 1.5 1638316800\n" +
+				"traffic.web_1.eu_west.hits 3 1638316800\n" +
+				"traffic.web2.hits 4 1638316800\n",
+		},
+		{
+			name:   "path",
+			config: map[string]string{"path": "{series}.{tags.zone}.{tags.host}.{key}", "batch_size": "1"},
+			expected: "traffic.eu_west.web_1.bytes 1.5 1638316800\n" +
+				"traffic.eu_west.web_1.hits 3 1638316800\n" +
+				"traffic.web2.hits 4 1638316800\n",
+		},
+		{
+			name:   "tagged",
+			config: map[string]string{"tagged": "true"},
+			expected: "traffic.bytes;host=web_1;zone=eu.west 1.5 1638316800\n" +
+				"traffic.hits;host=web_1;zone=eu.west 3 1638316800\n" +
+				"traffic.hits;host=web2 4 1638316800\n",
+		},
+		{
+			name:   "series",
+			config: map[string]string{"series": "web.traffic v2", "path": "{series}.{key}"},
+			expected: "web.traffic_v2.bytes 1.5 1638316800\n" +
+				"web.traffic_v2.hits 3 1638316800\n" +
+				"web.traffic_v2.hits 4 1638316800\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, received := receive(t)
+			test.config["addr"] = addr
+			if test.config["series"] == "" {
+				test.config["series"] = "traffic"
+			}
+
+			s, err := setup(test.config)
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if err = s.Write(points); err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			s.Close()
+
+			if got := string(<-received); got != test.expected {
+				t.Errorf("metrics are not as expected:\n%s\n%s", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestSendBackoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s, err := setup(map[string]string{"addr": addr, "series": "traffic", "retries": "3", "backoff": "10ms"})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	start := time.Now()
+	err = s.(*Graphite).send([]byte("traffic.hits 3 1638316800\n"))
+	if err == nil {
+		t.Errorf("error was expected, error was <nil>")
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("retries were expected to wait 10ms, 20ms and 40ms, all attempts took %s", elapsed)
+	}
+}
+
+func TestEncodePickle(t *testing.T) {
+	got := encodePickle([]metric{{path: "a.b", value: 1.5, timestamp: 1638316800}})
+
+	payload := []byte{0x80, 0x02, ']', '(', 'X', 3, 0, 0, 0, 'a', '.', 'b', 'J'}
+	ts := make([]byte, 4)
+	binary.LittleEndian.PutUint32(ts, 1638316800)
+	payload = append(payload, ts...)
+	payload = append(payload, 'G', 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0x86, 0x86, 'e', '.')
+	expected := append([]byte{0, 0, 0, byte(len(payload))}, payload...)
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("pickle is not as expected:\n%x\n%x", got, expected)
+	}
+}
@@ -2,28 +2,59 @@ package influx
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"traductio/internal/sink"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
 )
 
 func init() {
 	sink.Register("influx", setup)
 }
 
+const (
+	defaultBatchSize     = 5000
+	defaultConcurrency   = 4
+	defaultMaxRetries    = 3
+	defaultRetryInterval = time.Second
+)
+
+// Influx writes points to InfluxDB v2. The points are encoded as line
+// protocol and sent in batches of 'BatchSize' points, up to 'Concurrency'
+// batches are sent at the same time. Failed batches are retried with an
+// exponential backoff unless InfluxDB rejected them as invalid.
 type Influx struct {
-	Client influxdb2.Client
-	Bucket string
-	Org    string
-	Series string
+	Client        influxdb2.Client
+	Bucket        string
+	Org           string
+	Series        string
+	BatchSize     int
+	Concurrency   int
+	MaxRetries    int
+	RetryInterval time.Duration
+	Precision     time.Duration
 }
 
 func setup(config map[string]string) (sink.Sink, error) {
-	i := Influx{}
+	i := Influx{
+		BatchSize:     defaultBatchSize,
+		Concurrency:   defaultConcurrency,
+		MaxRetries:    defaultMaxRetries,
+		RetryInterval: defaultRetryInterval,
+		Precision:     time.Nanosecond,
+	}
 
 	var found bool
-	var addr, token, org, bucket, series string
+	var err error
+	var addr, token string
 
 	if addr, found = config["addr"]; !found || addr == "" {
 		return i, fmt.Errorf("sink requires field 'addr' to be set")
@@ -31,49 +62,159 @@ func setup(config map[string]string) (sink.Sink, error) {
 	if token, found = config["token"]; !found || token == "" {
 		return i, fmt.Errorf("sink requires field 'token' to be set")
 	}
-	if org, found = config["org"]; !found || org == "" {
-		return i, fmt.Errorf("sink requires field 'bucket' to be set")
+	if i.Org, found = config["org"]; !found || i.Org == "" {
+		return i, fmt.Errorf("sink requires field 'org' to be set")
 	}
-	if bucket, found = config["bucket"]; !found || bucket == "" {
+	if i.Bucket, found = config["bucket"]; !found || i.Bucket == "" {
 		return i, fmt.Errorf("sink requires field 'bucket' to be set")
 	}
-	if series, found = config["series"]; !found || series == "" {
+	if i.Series, found = config["series"]; !found || i.Series == "" {
 		return i, fmt.Errorf("sink requires field 'series' to be set")
 	}
+	if batchSize, found := config["batch_size"]; found && batchSize != "" {
+		if i.BatchSize, err = strconv.Atoi(batchSize); err != nil || i.BatchSize < 1 {
+			return i, fmt.Errorf("sink field 'batch_size' must be a positive number")
+		}
+	}
+	if concurrency, found := config["concurrency"]; found && concurrency != "" {
+		if i.Concurrency, err = strconv.Atoi(concurrency); err != nil || i.Concurrency < 1 {
+			return i, fmt.Errorf("sink field 'concurrency' must be a positive number")
+		}
+	}
+	if retries, found := config["max_retries"]; found && retries != "" {
+		if i.MaxRetries, err = strconv.Atoi(retries); err != nil || i.MaxRetries < 0 {
+			return i, fmt.Errorf("sink field 'max_retries' must be a number not below zero")
+		}
+	}
+	if interval, found := config["retry_interval"]; found && interval != "" {
+		if i.RetryInterval, err = time.ParseDuration(interval); err != nil {
+			return i, fmt.Errorf("sink field 'retry_interval' must be a duration: %s", err.Error())
+		}
+	}
+	if precision, found := config["precision"]; found && precision != "" {
+		if i.Precision, found = sink.Precisions[precision]; !found {
+			return i, fmt.Errorf("sink field 'precision' must be one of 's', 'ms', 'us' or 'ns'")
+		}
+	}
+	// all points are handed over at once, or per batch when streaming, and
+	// the outcome has to be known before Write returns, so there is nothing
+	// a flush interval could be applied to
+	if _, found := config["flush_interval"]; found {
+		return i, fmt.Errorf("sink field 'flush_interval' is not supported, points are sent in batches of 'batch_size' as soon as they are written")
+	}
+	useGzip := false
+	if gz, found := config["gzip"]; found && gz != "" {
+		if useGzip, err = strconv.ParseBool(gz); err != nil {
+			return i, fmt.Errorf("sink field 'gzip' must be either 'true' or 'false'")
+		}
+	}
 
-	c := influxdb2.NewClientWithOptions(addr, token, influxdb2.DefaultOptions().SetBatchSize(20))
+	// retries are handled by the sink itself, the retry queue of the client
+	// is only processed when further points are written
+	options := influxdb2.DefaultOptions().
+		SetPrecision(i.Precision).
+		SetUseGZip(useGzip).
+		SetMaxRetries(0)
+	i.Client = influxdb2.NewClientWithOptions(addr, token, options)
 
-	i = Influx{
-		Client: c,
-		Org:    org,
-		Bucket: bucket,
-		Series: series,
-	}
 	return i, nil
 }
 
 func (i Influx) Write(points []sink.Point) error {
-	writeAPI := i.Client.WriteAPIBlocking(i.Org, i.Bucket)
-
 	if len(points) < 1 {
 		return fmt.Errorf("no points to be written")
 	}
 
-	for _, p := range points {
-		values := map[string]interface{}{}
-		for k, v := range p.Values {
-			values[k] = v
+	writeAPI := i.Client.WriteAPIBlocking(i.Org, i.Bucket)
+
+	type batch struct {
+		first, last int
+		data        string
+	}
+	batches := make(chan batch)
+	errs := map[int]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < i.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := i.send(writeAPI.WriteRecord, b.data); err != nil {
+					mu.Lock()
+					errs[b.first] = fmt.Sprintf("points %d to %d: %s", b.first, b.last, err.Error())
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	var err error
+	for n := 0; n < len(points); n += i.BatchSize {
+		end := n + i.BatchSize
+		if end > len(points) {
+			end = len(points)
 		}
-		pt := influxdb2.NewPoint(i.Series, p.Tags, values, p.Timestamp)
-		err := writeAPI.WritePoint(context.Background(), pt)
+		var data []byte
+		data, err = sink.PointsAsLineProtocol(i.Series, points[n:end], i.Precision)
 		if err != nil {
-			return err
+			break
+		}
+		if len(data) > 0 {
+			batches <- batch{first: n + 1, last: end, data: strings.TrimSuffix(string(data), "\n")}
 		}
 	}
+	close(batches)
+	wg.Wait()
+
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		keys := []int{}
+		for k := range errs {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		msgs := []string{}
+		for _, k := range keys {
+			msgs = append(msgs, errs[k])
+		}
+		return fmt.Errorf("%d of %d batches failed: %s", len(errs), (len(points)+i.BatchSize-1)/i.BatchSize, strings.Join(msgs, "; "))
+	}
 
 	return nil
 }
 
+// send writes the batch, retrying it up to 'MaxRetries' times if the error
+// is not caused by the batch itself.
+func (i Influx) send(write func(context.Context, ...string) error, data string) error {
+	var err error
+	backoff := i.RetryInterval
+	for attempt := 0; attempt <= i.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err = write(context.Background(), data)
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryable returns false for errors which will not go away on retry such as
+// invalid line protocol or missing permissions.
+func retryable(err error) bool {
+	var herr *http2.Error
+	if errors.As(err, &herr) {
+		return herr.StatusCode == 0 || herr.StatusCode == http.StatusTooManyRequests || herr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
 func (i Influx) Close() {
 	i.Client.Close()
 }
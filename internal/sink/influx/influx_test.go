@@ -0,0 +1,104 @@
+package influx
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+)
+
+func TestWrite(t *testing.T) {
+	var mu sync.Mutex
+	received := []string{}
+	attempts := map[string]int{}
+
+	// the stand-in fails the first attempt of every batch with a temporary
+	// error and rejects batches containing the host 'invalid'
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("precision") != "s" || r.URL.Query().Get("bucket") != "traffic" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("could not decompress body: %s", err)
+			return
+		}
+		body, _ := ioutil.ReadAll(gz)
+
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[string(body)]++
+		if attempts[string(body)] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if strings.Contains(string(body), "invalid") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"invalid","message":"unable to parse"}`))
+			return
+		}
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s, err := setup(map[string]string{
+		"addr":           ts.URL,
+		"token":          "s3cr3t",
+		"org":            "example",
+		"bucket":         "traffic",
+		"series":         "requests",
+		"batch_size":     "2",
+		"precision":      "s",
+		"gzip":           "true",
+		"retry_interval": "1ms",
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	defer s.Close()
+
+	ts0 := time.Unix(1638316800, 0)
+	err = s.Write([]sink.Point{
+		{Timestamp: ts0, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 1}},
+		{Timestamp: ts0, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"hits": 2}},
+		{Timestamp: ts0, Tags: map[string]string{"host": "invalid"}, Values: map[string]float64{"hits": 3}},
+		{Timestamp: ts0, Tags: map[string]string{"host": "c"}, Values: map[string]float64{"hits": 4}},
+		{Timestamp: ts0, Tags: map[string]string{"host": "d"}, Values: map[string]float64{"hits": 5}},
+	})
+	if err == nil {
+		t.Fatalf("error was expected, error was <nil>")
+	}
+	if !strings.Contains(err.Error(), "1 of 3 batches failed: points 3 to 4:") {
+		t.Errorf("error does not report the failed batch: %s", err)
+	}
+
+	sort.Strings(received)
+	expected := []string{
+		"requests,host=a hits=1 1638316800\nrequests,host=b hits=2 1638316800\n",
+		"requests,host=d hits=5 1638316800\n",
+	}
+	if strings.Join(received, "|") != strings.Join(expected, "|") {
+		t.Errorf("batches are not as expected:\n%q\n%q", received, expected)
+	}
+}
+
+func TestSetupFlushInterval(t *testing.T) {
+	_, err := setup(map[string]string{
+		"addr":           "http://localhost:8086",
+		"token":          "token",
+		"org":            "org",
+		"bucket":         "bucket",
+		"series":         "series",
+		"flush_interval": "10s",
+	})
+	if err == nil || !strings.Contains(err.Error(), "flush_interval") {
+		t.Errorf("error about 'flush_interval' was expected, error was '%v'", err)
+	}
+}
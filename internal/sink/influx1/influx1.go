@@ -0,0 +1,171 @@
+package influx1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+)
+
+func init() {
+	sink.Register("influx1", setup)
+}
+
+const (
+	defaultBatchSize = 5000
+	defaultTimeout   = 30 * time.Second
+)
+
+// precisions maps the precisions to the values of the query parameter
+// understood by InfluxDB 1.x, VictoriaMetrics and QuestDB alike.
+var precisions = map[string]string{
+	"s":  "s",
+	"ms": "ms",
+	"us": "u",
+	"ns": "n",
+}
+
+// Influx1 sends points as line protocol to the '/write' endpoint of InfluxDB
+// 1.x or compatible databases such as VictoriaMetrics or QuestDB.
+type Influx1 struct {
+	Client    *http.Client
+	URL       string
+	Series    string
+	Precision time.Duration
+	BatchSize int
+	Gzip      bool
+	Username  string
+	Password  string
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	i := Influx1{
+		Precision: time.Nanosecond,
+		BatchSize: defaultBatchSize,
+	}
+
+	var found bool
+	var err error
+	var addr, db string
+
+	if addr, found = config["addr"]; !found || addr == "" {
+		return i, fmt.Errorf("sink requires field 'addr' to be set")
+	}
+	if db, found = config["db"]; !found || db == "" {
+		return i, fmt.Errorf("sink requires field 'db' to be set")
+	}
+	if i.Series, found = config["series"]; !found || i.Series == "" {
+		return i, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	precision := "ns"
+	if p, found := config["precision"]; found && p != "" {
+		precision = p
+	}
+	if i.Precision, found = sink.Precisions[precision]; !found {
+		return i, fmt.Errorf("sink field 'precision' must be one of 's', 'ms', 'us' or 'ns'")
+	}
+	if batchSize, found := config["batch_size"]; found && batchSize != "" {
+		if i.BatchSize, err = strconv.Atoi(batchSize); err != nil || i.BatchSize < 1 {
+			return i, fmt.Errorf("sink field 'batch_size' must be a positive number")
+		}
+	}
+	if gz, found := config["gzip"]; found && gz != "" {
+		if i.Gzip, err = strconv.ParseBool(gz); err != nil {
+			return i, fmt.Errorf("sink field 'gzip' must be either 'true' or 'false'")
+		}
+	}
+	timeout := defaultTimeout
+	if t, found := config["timeout"]; found && t != "" {
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return i, fmt.Errorf("sink field 'timeout' must be a duration: %s", err.Error())
+		}
+	}
+	i.Username = config["username"]
+	i.Password = config["password"]
+
+	u, err := url.Parse(strings.TrimSuffix(addr, "/") + "/write")
+	if err != nil {
+		return i, fmt.Errorf("sink field 'addr' is not a valid URL: %s", err.Error())
+	}
+	q := u.Query()
+	q.Set("db", db)
+	if rp := config["rp"]; rp != "" {
+		q.Set("rp", rp)
+	}
+	q.Set("precision", precisions[precision])
+	u.RawQuery = q.Encode()
+	i.URL = u.String()
+
+	i.Client = &http.Client{Timeout: timeout}
+	return i, nil
+}
+
+func (i Influx1) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	for n := 0; n < len(points); n += i.BatchSize {
+		end := n + i.BatchSize
+		if end > len(points) {
+			end = len(points)
+		}
+		data, err := sink.PointsAsLineProtocol(i.Series, points[n:end], i.Precision)
+		if err != nil {
+			return err
+		}
+		if err = i.send(data); err != nil {
+			return fmt.Errorf("error while writing points %d to %d: %s", n+1, end, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (i Influx1) send(data []byte) error {
+	var body bytes.Buffer
+	if i.Gzip {
+		gz := gzip.NewWriter(&body)
+		if _, err := gz.Write(data); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	} else {
+		body.Write(data)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "traductio")
+	if i.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if i.Username != "" {
+		req.SetBasicAuth(i.Username, i.Password)
+	}
+
+	resp, err := i.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP status code is %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (i Influx1) Close() {}
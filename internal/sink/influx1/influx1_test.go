@@ -0,0 +1,82 @@
+package influx1
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+)
+
+func TestWrite(t *testing.T) {
+	received := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/write" || q.Get("db") != "metrics" || q.Get("rp") != "weekly" || q.Get("precision") != "ms" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		if user, pass, _ := r.BasicAuth(); user != "traductio" || pass != "s3cr3t" {
+			t.Errorf("unexpected credentials %s:%s", user, pass)
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("body is not compressed")
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("could not decompress body: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(gz)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s, err := setup(map[string]string{
+		"addr":       ts.URL,
+		"db":         "metrics",
+		"rp":         "weekly",
+		"series":     "traffic",
+		"precision":  "ms",
+		"username":   "traductio",
+		"password":   "s3cr3t",
+		"gzip":       "true",
+		"batch_size": "2",
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	ts0 := time.Unix(1638316800, 0)
+	err = s.Write([]sink.Point{
+		{Timestamp: ts0, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 3}},
+		{Timestamp: ts0, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"hits": 4}},
+		{Timestamp: ts0.Add(time.Millisecond), Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 5}},
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	expected := "traffic,host=a hits=3 1638316800000\ntraffic,host=b hits=4 1638316800000\n" +
+		"|traffic,host=a hits=5 1638316800001\n"
+	if got := strings.Join(received, "|"); got != expected {
+		t.Errorf("batches are not as expected:\n%s\n%s", got, expected)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	s, _ := setup(map[string]string{"addr": ts.URL, "db": "metrics", "series": "traffic"})
+	err := s.Write([]sink.Point{{Timestamp: time.Now(), Values: map[string]float64{"hits": 1}}})
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("error containing the response was expected, error was '%v'", err)
+	}
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// Precisions maps the precisions supported by the InfluxDB line protocol to
+// the duration of a timestamp unit.
+var Precisions = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// PointsAsLineProtocol renders the points as InfluxDB line protocol using
+// 'series' as measurement. Tags and fields are sorted by key, tags with empty
+// values as well as values that are not finite are omitted. The timestamps
+// are truncated to the precision passed.
+func PointsAsLineProtocol(series string, points []Point, precision time.Duration) ([]byte, error) {
+	if series == "" {
+		return []byte{}, fmt.Errorf("line protocol requires a series to be set")
+	}
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+
+	var out bytes.Buffer
+	for _, p := range points {
+		fields := []string{}
+		for _, k := range sortedValueKeys(p.Values) {
+			v := p.Values[k]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			fields = append(fields, keyEscaper.Replace(k)+"="+strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		out.WriteString(measurementEscaper.Replace(series))
+		for _, k := range sortedTagKeys(p.Tags) {
+			if p.Tags[k] == "" {
+				continue
+			}
+			out.WriteString("," + keyEscaper.Replace(k) + "=" + keyEscaper.Replace(p.Tags[k]))
+		}
+		out.WriteString(" " + strings.Join(fields, ","))
+		out.WriteString(" " + strconv.FormatInt(p.Timestamp.UnixNano()/int64(precision), 10))
+		out.WriteString("\n")
+	}
+
+	return out.Bytes(), nil
+}
+
+func sortedTagKeys(m map[string]string) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortedValueKeys(m map[string]float64) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,28 @@
+package sink
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPointsAsLineProtocol(t *testing.T) {
+	ts := time.Unix(1638316800, 123456789)
+	points := []Point{
+		{Timestamp: ts, Tags: map[string]string{"path": "/a b,c=d", "empty": ""}, Values: map[string]float64{"hits": 3, "bytes sent": 1.25}},
+		{Timestamp: ts, Tags: map[string]string{}, Values: map[string]float64{"nan": math.NaN()}},
+	}
+
+	got, err := PointsAsLineProtocol("web traffic", points, time.Millisecond)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	expected := `web\ traffic,path=/a\ b\,c\=d bytes\ sent=1.25,hits=3 1638316800123` + "\n"
+	if string(got) != expected {
+		t.Errorf("line protocol is not as expected:\n%s\n%s", string(got), expected)
+	}
+
+	if _, err = PointsAsLineProtocol("", points, time.Second); err == nil {
+		t.Errorf("error was expected, error was <nil>")
+	}
+}
@@ -0,0 +1,278 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+
+	collector "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	sink.Register("otlp", setup)
+}
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+
+	defaultBatchSize   = 1000
+	defaultTimeout     = 30 * time.Second
+	defaultHTTPPath    = "/v1/metrics"
+	defaultServiceName = "traductio"
+
+	resourcePrefix = "resource."
+	headerPrefix   = "header."
+)
+
+// OTLP sends points as gauge data points to an OpenTelemetry Collector or any
+// other receiver of the OpenTelemetry protocol. Each value of a point becomes
+// a data point of the metric '<series>.<value key>' with the tags of the point
+// as attributes. Connection fields prefixed with 'resource.' are sent as
+// resource attributes, fields prefixed with 'header.' as HTTP headers or gRPC
+// metadata.
+type OTLP struct {
+	Endpoint  string
+	Protocol  string
+	Series    string
+	BatchSize int
+	Timeout   time.Duration
+	Resource  map[string]string
+	Headers   map[string]string
+
+	client *http.Client
+	conn   *grpc.ClientConn
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	o := &OTLP{
+		Protocol:  ProtocolHTTP,
+		BatchSize: defaultBatchSize,
+		Timeout:   defaultTimeout,
+		Resource:  map[string]string{"service.name": defaultServiceName},
+		Headers:   map[string]string{},
+	}
+
+	var found bool
+	var err error
+
+	if o.Endpoint, found = config["endpoint"]; !found || o.Endpoint == "" {
+		return o, fmt.Errorf("sink requires field 'endpoint' to be set")
+	}
+	if o.Series, found = config["series"]; !found || o.Series == "" {
+		return o, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	if protocol, found := config["protocol"]; found && protocol != "" {
+		o.Protocol = protocol
+	}
+	if batchSize, found := config["batch_size"]; found && batchSize != "" {
+		if o.BatchSize, err = strconv.Atoi(batchSize); err != nil || o.BatchSize < 1 {
+			return o, fmt.Errorf("sink field 'batch_size' must be a positive number")
+		}
+	}
+	if timeout, found := config["timeout"]; found && timeout != "" {
+		if o.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return o, fmt.Errorf("sink field 'timeout' must be a duration: %s", err.Error())
+		}
+	}
+	insecure := false
+	if i, found := config["insecure"]; found && i != "" {
+		if insecure, err = strconv.ParseBool(i); err != nil {
+			return o, fmt.Errorf("sink field 'insecure' must be either 'true' or 'false'")
+		}
+	}
+	for k, v := range config {
+		if strings.HasPrefix(k, resourcePrefix) {
+			o.Resource[strings.TrimPrefix(k, resourcePrefix)] = v
+		} else if strings.HasPrefix(k, headerPrefix) {
+			o.Headers[strings.TrimPrefix(k, headerPrefix)] = v
+		}
+	}
+
+	switch o.Protocol {
+	case ProtocolHTTP:
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return o, fmt.Errorf("sink field 'endpoint' must be an HTTP URL when using protocol '%s'", ProtocolHTTP)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = defaultHTTPPath
+			o.Endpoint = u.String()
+		}
+		o.client = &http.Client{Timeout: o.Timeout}
+	case ProtocolGRPC:
+		creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+		if insecure {
+			creds = grpc.WithInsecure()
+		}
+		o.conn, err = grpc.Dial(o.Endpoint, creds)
+		if err != nil {
+			return o, fmt.Errorf("error while connecting to %s: %s", o.Endpoint, err.Error())
+		}
+	default:
+		return o, fmt.Errorf("sink field 'protocol' must be either '%s' or '%s'", ProtocolHTTP, ProtocolGRPC)
+	}
+
+	return o, nil
+}
+
+func (o *OTLP) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	type dataPoint struct {
+		name  string
+		point *metrics.NumberDataPoint
+	}
+	dataPoints := []dataPoint{}
+	for _, p := range points {
+		attributes := attributes(p.Tags)
+		keys := []string{}
+		for k := range p.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := p.Values[k]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			dataPoints = append(dataPoints, dataPoint{
+				name: o.Series + "." + k,
+				point: &metrics.NumberDataPoint{
+					Attributes:   attributes,
+					TimeUnixNano: uint64(p.Timestamp.UnixNano()),
+					Value:        &metrics.NumberDataPoint_AsDouble{AsDouble: v},
+				},
+			})
+		}
+	}
+
+	for i := 0; i < len(dataPoints); i += o.BatchSize {
+		end := i + o.BatchSize
+		if end > len(dataPoints) {
+			end = len(dataPoints)
+		}
+
+		// data points of the same metric are grouped into one gauge
+		index := map[string]*metrics.Gauge{}
+		ms := []*metrics.Metric{}
+		for _, dp := range dataPoints[i:end] {
+			gauge, ok := index[dp.name]
+			if !ok {
+				gauge = &metrics.Gauge{}
+				index[dp.name] = gauge
+				ms = append(ms, &metrics.Metric{Name: dp.name, Data: &metrics.Metric_Gauge{Gauge: gauge}})
+			}
+			gauge.DataPoints = append(gauge.DataPoints, dp.point)
+		}
+
+		req := &collector.ExportMetricsServiceRequest{
+			ResourceMetrics: []*metrics.ResourceMetrics{
+				{
+					Resource: &resource.Resource{Attributes: attributes(o.Resource)},
+					InstrumentationLibraryMetrics: []*metrics.InstrumentationLibraryMetrics{
+						{
+							InstrumentationLibrary: &common.InstrumentationLibrary{Name: defaultServiceName},
+							Metrics:                ms,
+						},
+					},
+				},
+			},
+		}
+
+		var err error
+		if o.Protocol == ProtocolGRPC {
+			err = o.sendGRPC(req)
+		} else {
+			err = o.sendHTTP(req)
+		}
+		if err != nil {
+			return fmt.Errorf("error while sending data points %d to %d: %s", i+1, end, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (o *OTLP) sendHTTP(req *collector.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("User-Agent", "traductio")
+	for k, v := range o.Headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned HTTP status code %d: %s", o.Endpoint, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (o *OTLP) sendGRPC(req *collector.ExportMetricsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.Timeout)
+	defer cancel()
+	for k, v := range o.Headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(k), v)
+	}
+
+	_, err := collector.NewMetricsServiceClient(o.conn).Export(ctx, req)
+	return err
+}
+
+func (o *OTLP) Close() {
+	if o.conn != nil {
+		o.conn.Close()
+	}
+}
+
+// attributes returns the map as attributes sorted by key.
+func attributes(m map[string]string) []*common.KeyValue {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := []*common.KeyValue{}
+	for _, k := range keys {
+		out = append(out, &common.KeyValue{
+			Key:   k,
+			Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: m[k]}},
+		})
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package otlp
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+
+	collector "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiver collects the requests exported to it via gRPC or HTTP.
+type receiver struct {
+	collector.UnimplementedMetricsServiceServer
+	mu       sync.Mutex
+	requests []*collector.ExportMetricsServiceRequest
+	tokens   []string
+}
+
+func (r *receiver) Export(ctx context.Context, req *collector.ExportMetricsServiceRequest) (*collector.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.tokens = append(r.tokens, md.Get("x-token")...)
+	return &collector.ExportMetricsServiceResponse{}, nil
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	msg := &collector.ExportMetricsServiceRequest{}
+	if req.URL.Path != "/v1/metrics" || proto.Unmarshal(body, msg) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, msg)
+	r.tokens = append(r.tokens, req.Header.Get("X-Token"))
+}
+
+// summary returns '<resource>|<metric>|<attributes>|<time>|<value>' for each
+// data point received.
+func (r *receiver) summary() []string {
+	out := []string{}
+	for _, req := range r.requests {
+		for _, rm := range req.ResourceMetrics {
+			res := ""
+			for _, a := range rm.Resource.Attributes {
+				res += a.Key + "=" + a.Value.GetStringValue() + ","
+			}
+			for _, il := range rm.InstrumentationLibraryMetrics {
+				for _, m := range il.Metrics {
+					for _, dp := range m.GetGauge().DataPoints {
+						attrs := ""
+						for _, a := range dp.Attributes {
+							attrs += a.Key + "=" + a.Value.GetStringValue() + ","
+						}
+						out = append(out, res+"|"+m.Name+"|"+attrs+"|"+time.Unix(0, int64(dp.TimeUnixNano)).UTC().Format(time.RFC3339)+"|"+strconv.FormatFloat(dp.GetAsDouble(), 'f', -1, 64))
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestWrite(t *testing.T) {
+	ts := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	points := []sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 3, "bytes": 1.5}},
+		{Timestamp: ts.Add(time.Minute), Tags: map[string]string{"host": "b"}, Values: map[string]float64{"hits": 4}},
+	}
+	expected := []string{
+		"service.name=traductio,tenant=x,|traffic.bytes|host=a,|2021-12-01T00:00:00Z|1.5",
+		"service.name=traductio,tenant=x,|traffic.hits|host=a,|2021-12-01T00:00:00Z|3",
+		"service.name=traductio,tenant=x,|traffic.hits|host=b,|2021-12-01T00:01:00Z|4",
+	}
+
+	r := &receiver{}
+
+	ts1 := httptest.NewServer(r)
+	defer ts1.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	server := grpc.NewServer()
+	collector.RegisterMetricsServiceServer(server, r)
+	go server.Serve(l)
+	defer server.Stop()
+
+	tests := []struct {
+		name     string
+		config   map[string]string
+		requests int
+	}{
+		{
+			name:     "http",
+			config:   map[string]string{"endpoint": ts1.URL},
+			requests: 1,
+		},
+		{
+			name:     "grpc",
+			config:   map[string]string{"endpoint": l.Addr().String(), "protocol": "grpc", "insecure": "true", "batch_size": "2"},
+			requests: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r.requests, r.tokens = nil, nil
+			test.config["series"] = "traffic"
+			test.config["resource.tenant"] = "x"
+			test.config["header.X-Token"] = "s3cr3t"
+
+			s, err := setup(test.config)
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			defer s.Close()
+			if err = s.Write(points); err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+
+			if len(r.requests) != test.requests {
+				t.Errorf("%d requests were expected, got %d", test.requests, len(r.requests))
+			}
+			for _, token := range r.tokens {
+				if token != "s3cr3t" {
+					t.Errorf("header was not sent, got '%s'", token)
+				}
+			}
+			if got := r.summary(); !reflect.DeepEqual(got, expected) {
+				t.Errorf("data points are not as expected:\n%v\n%v", got, expected)
+			}
+		})
+	}
+}
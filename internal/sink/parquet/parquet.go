@@ -0,0 +1,264 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+	"traductio/internal/sink/storage"
+
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func init() {
+	sink.Register("parquet", setup)
+}
+
+const (
+	timeColumn       = "time"
+	defaultPartition = "date={date}"
+)
+
+var placeholder = regexp.MustCompile(`\{(date|year|month|day)\}`)
+
+var compressions = map[string]pq.CompressionCodec{
+	"snappy": pq.CompressionCodec_SNAPPY,
+	"gzip":   pq.CompressionCodec_GZIP,
+	"none":   pq.CompressionCodec_UNCOMPRESSED,
+}
+
+// Parquet writes the points to Apache Parquet files partitioned by day. Each
+// file has a timestamp column 'time', a string column per tag and a double
+// column per value. The directory of a day is given by the template
+// Partition which supports the placeholders '{date}' (e.g. 2021-12-01),
+// '{year}', '{month}' and '{day}'.
+type Parquet struct {
+	Path        string
+	Series      string
+	Partition   string
+	Compression pq.CompressionCodec
+
+	tags   []string
+	values []string
+	// writes counts the calls of Write, streamed inputs are written in
+	// several batches
+	writes int
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	p := &Parquet{
+		Partition:   defaultPartition,
+		Compression: pq.CompressionCodec_SNAPPY,
+	}
+
+	var found bool
+
+	if p.Path, found = config["path"]; !found || p.Path == "" {
+		return p, fmt.Errorf("sink requires field 'path' to be set")
+	}
+	if p.Series, found = config["series"]; !found || p.Series == "" {
+		return p, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	if partition, found := config["partition"]; found && partition != "" {
+		p.Partition = partition
+	}
+	if strings.ContainsAny(placeholder.ReplaceAllString(p.Partition, ""), "{}") {
+		return p, fmt.Errorf("sink field 'partition' supports only the placeholders '{date}', '{year}', '{month}' and '{day}'")
+	}
+	if compression, found := config["compression"]; found && compression != "" {
+		if p.Compression, found = compressions[compression]; !found {
+			return p, fmt.Errorf("sink field 'compression' must be one of 'snappy', 'gzip' or 'none'")
+		}
+	}
+
+	return p, nil
+}
+
+// Prepare sets the columns of the files written, this keeps the schema of
+// all files of a series the same even if some tags or values are missing in
+// a run.
+func (p *Parquet) Prepare(tags, values []string) error {
+	if err := validate(tags, values); err != nil {
+		return err
+	}
+	p.tags, p.values = tags, values
+	return nil
+}
+
+// Write writes one file per day the points belong to. The files are named
+// after the series and the first and last timestamp of the points they hold,
+// a run repeated for the same time range replaces its files. Files of
+// follow-up calls within the same run are suffixed with the number of the
+// call, so batches spanning the same time range do not replace each other.
+func (p *Parquet) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	tags, values := sink.Structure(points)
+	tags, values = union(p.tags, tags), union(p.values, values)
+	if err := validate(tags, values); err != nil {
+		return err
+	}
+	schema, err := schema(tags, values)
+	if err != nil {
+		return err
+	}
+
+	partitions := map[string][]sink.Point{}
+	for _, point := range points {
+		key := p.partition(point.Timestamp)
+		partitions[key] = append(partitions[key], point)
+	}
+	keys := []string{}
+	for k := range partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	suffix := ""
+	if p.writes > 0 {
+		suffix = fmt.Sprintf("_%d", p.writes)
+	}
+	p.writes++
+
+	for _, key := range keys {
+		data, first, last, err := p.encode(schema, tags, values, partitions[key])
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s_%d_%d%s.parquet", p.Series, first, last, suffix)
+		path := storage.Join(p.Path, key, name)
+		if err = storage.Write(path, data, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partition returns the directory of the day the timestamp belongs to.
+func (p *Parquet) partition(ts time.Time) string {
+	ts = ts.UTC()
+	return placeholder.ReplaceAllStringFunc(p.Partition, func(ph string) string {
+		switch strings.Trim(ph, "{}") {
+		case "year":
+			return ts.Format("2006")
+		case "month":
+			return ts.Format("01")
+		case "day":
+			return ts.Format("02")
+		}
+		return ts.Format("2006-01-02")
+	})
+}
+
+// encode returns the points as Parquet file along with the first and the
+// last timestamp as unix seconds.
+func (p *Parquet) encode(schema string, tags, values []string, points []sink.Point) ([]byte, int64, int64, error) {
+	var out bytes.Buffer
+	var first, last int64
+
+	w, err := writer.NewJSONWriterFromWriter(schema, &out, 1)
+	if err != nil {
+		return []byte{}, first, last, fmt.Errorf("could not create parquet writer: %s", err.Error())
+	}
+	w.CompressionType = p.Compression
+
+	for i, point := range points {
+		ts := point.Timestamp.Unix()
+		if i == 0 || ts < first {
+			first = ts
+		}
+		if i == 0 || ts > last {
+			last = ts
+		}
+
+		row := map[string]interface{}{
+			timeColumn: point.Timestamp.UnixNano() / 1000,
+		}
+		for _, t := range tags {
+			if v, ok := point.Tags[t]; ok {
+				row[t] = v
+			}
+		}
+		for _, v := range values {
+			if value, ok := point.Values[v]; ok {
+				row[v] = value
+			}
+		}
+		b, err := json.Marshal(row)
+		if err != nil {
+			return []byte{}, first, last, fmt.Errorf("could not encode point: %s", err.Error())
+		}
+		if err = w.Write(string(b)); err != nil {
+			return []byte{}, first, last, fmt.Errorf("could not write point: %s", err.Error())
+		}
+	}
+
+	if err = w.WriteStop(); err != nil {
+		return []byte{}, first, last, fmt.Errorf("could not finish parquet file: %s", err.Error())
+	}
+	return out.Bytes(), first, last, nil
+}
+
+func (p *Parquet) Close() {}
+
+type field struct {
+	Tag    string  `json:"Tag"`
+	Fields []field `json:"Fields,omitempty"`
+}
+
+// schema returns the JSON schema of the parquet writer. The time is stored
+// in microseconds which is supported by most query engines.
+func schema(tags, values []string) (string, error) {
+	root := field{
+		Tag: "name=parquet_go_root, repetitiontype=REQUIRED",
+		Fields: []field{
+			{Tag: "name=" + timeColumn + ", type=INT64, convertedtype=TIMESTAMP_MICROS, repetitiontype=REQUIRED"},
+		},
+	}
+	for _, t := range tags {
+		root.Fields = append(root.Fields, field{Tag: "name=" + t + ", type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"})
+	}
+	for _, v := range values {
+		root.Fields = append(root.Fields, field{Tag: "name=" + v + ", type=DOUBLE, repetitiontype=OPTIONAL"})
+	}
+	b, err := json.Marshal(root)
+	return string(b), err
+}
+
+// union appends the names missing in known.
+func union(known, names []string) []string {
+	out := append([]string{}, known...)
+	k := map[string]bool{}
+	for _, n := range known {
+		k[n] = true
+	}
+	for _, n := range names {
+		if !k[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// validate checks if the names can be used as columns.
+func validate(tags, values []string) error {
+	seen := map[string]bool{timeColumn: true}
+	for _, name := range append(append([]string{}, tags...), values...) {
+		if seen[name] {
+			return fmt.Errorf("column '%s' is not unique, tags and values cannot share names or be named '%s'", name, timeColumn)
+		}
+		if strings.ContainsAny(name, ",=") {
+			return fmt.Errorf("column '%s' cannot contain ',' or '='", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// file provides a parquet file read into memory to the parquet reader.
+type file struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newFile(data []byte) file {
+	return file{Reader: bytes.NewReader(data), data: data}
+}
+
+func (f file) Write(p []byte) (int, error)                  { return 0, nil }
+func (f file) Close() error                                 { return nil }
+func (f file) Open(name string) (source.ParquetFile, error) { return newFile(f.data), nil }
+func (f file) Create(string) (source.ParquetFile, error)    { return f, nil }
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := setup(map[string]string{"path": dir, "series": "traffic"})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	err = s.Write([]sink.Point{
+		{Timestamp: day.Add(time.Hour), Tags: map[string]string{"host": "a"}, Values: map[string]float64{"bytes": 1.5, "hits": 3}},
+		{Timestamp: day.Add(2 * time.Hour), Tags: map[string]string{"host": "b"}, Values: map[string]float64{"bytes": 2}},
+		{Timestamp: day.Add(25 * time.Hour), Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 4}},
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{
+			path: "date=2021-12-01/traffic_1638320400_1638324000.parquet",
+			expected: `[{"Time":1638320400000000,"Host":"a","Bytes":1.5,"Hits":3},` +
+				`{"Time":1638324000000000,"Host":"b","Bytes":2,"Hits":null}]`,
+		},
+		{
+			path:     "date=2021-12-02/traffic_1638406800_1638406800.parquet",
+			expected: `[{"Time":1638406800000000,"Host":"a","Bytes":null,"Hits":4}]`,
+		},
+	}
+
+	for _, test := range tests {
+		got := readRows(t, filepath.Join(dir, test.path))
+		var expected interface{}
+		json.Unmarshal([]byte(test.expected), &expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("rows of %s are not as expected: %v", test.path, got)
+		}
+	}
+}
+
+func TestWriteBatches(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := setup(map[string]string{"path": dir, "series": "traffic"})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	// unsorted batches of a streamed input covering the same time range
+	batches := [][]sink.Point{
+		{
+			{Timestamp: day.Add(2 * time.Hour), Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 1}},
+			{Timestamp: day.Add(time.Hour), Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 2}},
+		},
+		{
+			{Timestamp: day.Add(time.Hour), Tags: map[string]string{"host": "b"}, Values: map[string]float64{"hits": 3}},
+			{Timestamp: day.Add(2 * time.Hour), Tags: map[string]string{"host": "b"}, Values: map[string]float64{"hits": 4}},
+		},
+		{
+			{Timestamp: day.Add(2 * time.Hour), Tags: map[string]string{"host": "c"}, Values: map[string]float64{"hits": 5}},
+			{Timestamp: day.Add(time.Hour), Tags: map[string]string{"host": "c"}, Values: map[string]float64{"hits": 6}},
+		},
+	}
+	for _, batch := range batches {
+		if err := s.Write(batch); err != nil {
+			t.Fatalf("no error was expected, error was '%s'", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "date=2021-12-01", "*.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"traffic_1638320400_1638324000.parquet",
+		"traffic_1638320400_1638324000_1.parquet",
+		"traffic_1638320400_1638324000_2.parquet",
+	}
+	names := []string{}
+	hits := 0.0
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+		for _, row := range readRows(t, f).([]interface{}) {
+			hits += row.(map[string]interface{})["Hits"].(float64)
+		}
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("files are not as expected: %v", names)
+	}
+	if hits != 21 {
+		t.Errorf("all points of all batches were expected to be written, hits sum up to %f", hits)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	ts := time.Date(2021, 12, 1, 23, 30, 0, 0, time.FixedZone("CET", -3600))
+
+	tests := []struct {
+		name        string
+		partition   string
+		errExpected bool
+		expected    string
+	}{
+		{name: "default", expected: "date=2021-12-02"},
+		{name: "parts", partition: "data/region=21/year={year}/month={month}/day={day}", expected: "data/region=21/year=2021/month=12/day=02"},
+		{name: "layout", partition: "region=Jan/2006-01-02", expected: "region=Jan/2006-01-02"},
+		{name: "unknown", partition: "hour={hour}", errExpected: true},
+		{name: "unclosed", partition: "date={date", errExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := setup(map[string]string{"path": "out", "series": "traffic", "partition": test.partition})
+			if err == nil && test.errExpected {
+				t.Fatalf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if test.errExpected {
+				return
+			}
+			if got := s.(*Parquet).partition(ts); got != test.expected {
+				t.Errorf("partition '%s' was expected, got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+// readRows returns the rows of the parquet file at path decoded from JSON.
+func readRows(t *testing.T, path string) interface{} {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	r, err := reader.NewParquetReader(newFile(data), nil, 1)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	rows, err := r.ReadByNumber(int(r.GetNumRows()))
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	r.ReadStop()
+
+	var got interface{}
+	b, _ := json.Marshal(rows)
+	json.Unmarshal(b, &got)
+	return got
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"reflect"
+	"sort"
 	"text/tabwriter"
 	"time"
 )
@@ -67,6 +68,29 @@ func TrimPoints(points []Point) ([]Point, time.Time, time.Time) {
 	return out, earliest, latest
 }
 
+// Structure returns the sorted names of all tags and values of the points.
+func Structure(points []Point) ([]string, []string) {
+	tags, values := map[string]bool{}, map[string]bool{}
+	for _, p := range points {
+		for k := range p.Tags {
+			tags[k] = true
+		}
+		for k := range p.Values {
+			values[k] = true
+		}
+	}
+	return sortedKeys(tags), sortedKeys(values)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func (p Point) String() string {
 	out := new(bytes.Buffer)
 	timestamp := p.Timestamp.Format("2006-01-02 15:04:05")
@@ -136,6 +160,8 @@ func (p Point) IsEmpty() bool {
 	return true
 }
 
+// PointsAsCSV renders the points as CSV table. The columns are 'time'
+// followed by the sorted tags and the sorted values.
 func PointsAsCSV(points []Point, delimiter string) ([]byte, error) {
 	var err error
 	var out bytes.Buffer
@@ -145,55 +171,45 @@ func PointsAsCSV(points []Point, delimiter string) ([]byte, error) {
 		return []byte{}, err
 	}
 
-	cols := map[string]bool{
-		"time": true,
-	}
-
-	data := []map[string]string{}
-
+	header := CSVHeader(points)
+	table := [][]string{header}
 	for _, p := range points {
-		row := map[string]string{
-			"time": p.Timestamp.String(),
-		}
-
-		for k, v := range p.Tags {
-			cols[k] = true
-			row[k] = v
-
-		}
-
-		for k, v := range p.Values {
-			cols[k] = true
-			row[k] = fmt.Sprintf("%f", v)
-		}
-
-		data = append(data, row)
+		table = append(table, CSVRecord(p, header))
 	}
 
-	table := [][]string{}
+	err = writer.WriteAll(table)
+	return out.Bytes(), err
+}
 
-	// write header to table
-	header := []string{}
-	for columnName := range cols {
-		header = append(header, columnName)
+// CSVHeader returns the columns of a CSV table holding the points: 'time'
+// followed by the sorted tags and the sorted values.
+func CSVHeader(points []Point) []string {
+	tags, values := Structure(points)
+	header := []string{"time"}
+	seen := map[string]bool{"time": true}
+	for _, col := range append(tags, values...) {
+		if !seen[col] {
+			header = append(header, col)
+			seen[col] = true
+		}
 	}
-	table = append(table, header)
+	return header
+}
 
-	// fill data rows
-	for _, rowData := range data {
-		row := []string{}
-		for _, colName := range header {
-			if v, ok := rowData[colName]; ok {
-				row = append(row, v)
-			} else {
-				row = append(row, "")
-			}
+// CSVRecord returns the fields of the point in the order of the header
+// passed, columns the point does not provide are left empty.
+func CSVRecord(p Point, header []string) []string {
+	row := []string{}
+	for _, col := range header {
+		if col == "time" {
+			row = append(row, p.Timestamp.String())
+		} else if v, ok := p.Values[col]; ok {
+			row = append(row, fmt.Sprintf("%f", v))
+		} else {
+			row = append(row, p.Tags[col])
 		}
-		table = append(table, row)
 	}
-
-	err = writer.WriteAll(table)
-	return out.Bytes(), err
+	return row
 }
 
 func asSingleRune(in string) (rune, error) {
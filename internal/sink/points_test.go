@@ -0,0 +1,28 @@
+package sink
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPointsAsCSV(t *testing.T) {
+	ts := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Timestamp: ts, Tags: map[string]string{"zone": "x", "host": "a"}, Values: map[string]float64{"hits": 3, "bytes": 1}},
+		{Timestamp: ts, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"active": 2}},
+	}
+	expected := "time;host;zone;active;bytes;hits\n" +
+		"2021-12-01 00:00:00 +0000 UTC;a;x;;1.000000;3.000000\n" +
+		"2021-12-01 00:00:00 +0000 UTC;b;;2.000000;;\n"
+
+	// the column order must not depend on the map iteration order
+	for i := 0; i < 10; i++ {
+		got, err := PointsAsCSV(points, ";")
+		if err != nil {
+			t.Fatalf("no error was expected, error was '%s'", err)
+		}
+		if string(got) != expected {
+			t.Fatalf("CSV is not as expected:\n%s\n%s", string(got), expected)
+		}
+	}
+}
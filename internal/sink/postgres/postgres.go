@@ -0,0 +1,409 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/lib/pq"
+)
+
+func init() {
+	sink.Register("postgres", setup)
+}
+
+const (
+	timeColumn           = "time"
+	defaultSchema        = "public"
+	defaultCopyThreshold = 1000
+	// PostgreSQL allows at most 65535 parameters per statement
+	maxParams = 65535
+)
+
+// Postgres writes points into a table of PostgreSQL or TimescaleDB. The table
+// has a column 'time', a text column per tag and a double precision column
+// per value. Points with the same time and tags are upserted.
+type Postgres struct {
+	DB            *sql.DB
+	Schema        string
+	Table         string
+	Create        bool
+	Hypertable    bool
+	CopyThreshold int
+
+	tags     []string
+	values   []string
+	prepared bool
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	p := &Postgres{
+		Schema:        defaultSchema,
+		Create:        true,
+		CopyThreshold: defaultCopyThreshold,
+	}
+
+	var found bool
+	var dsn string
+	var err error
+
+	if dsn, found = config["dsn"]; !found || dsn == "" {
+		return p, fmt.Errorf("sink requires field 'dsn' to be set")
+	}
+	if p.Table, found = config["series"]; !found || p.Table == "" {
+		return p, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	if schema, found := config["schema"]; found && schema != "" {
+		p.Schema = schema
+	}
+	if create, found := config["create"]; found && create != "" {
+		if p.Create, err = strconv.ParseBool(create); err != nil {
+			return p, fmt.Errorf("sink field 'create' must be either 'true' or 'false'")
+		}
+	}
+	if hypertable, found := config["hypertable"]; found && hypertable != "" {
+		if p.Hypertable, err = strconv.ParseBool(hypertable); err != nil {
+			return p, fmt.Errorf("sink field 'hypertable' must be either 'true' or 'false'")
+		}
+	}
+	if threshold, found := config["copy_threshold"]; found && threshold != "" {
+		if p.CopyThreshold, err = strconv.Atoi(threshold); err != nil || p.CopyThreshold < 1 {
+			return p, fmt.Errorf("sink field 'copy_threshold' must be a positive number")
+		}
+	}
+
+	p.DB, err = sql.Open("postgres", dsn)
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Prepare creates the table or validates the existing one based on the tags
+// and values passed.
+func (p *Postgres) Prepare(tags, values []string) error {
+	if err := p.ensureTable(tags, values); err != nil {
+		return err
+	}
+	p.tags, p.values, p.prepared = tags, values, true
+	return nil
+}
+
+func (p *Postgres) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	tags, values := sink.Structure(points)
+	if !p.prepared {
+		if err := p.Prepare(tags, values); err != nil {
+			return err
+		}
+	} else if err := contains(p.tags, tags, "tag"); err != nil {
+		return err
+	} else if err := contains(p.values, values, "value"); err != nil {
+		return err
+	}
+
+	points = deduplicate(points)
+	if len(points) >= p.CopyThreshold {
+		return p.copy(points)
+	}
+	return p.insert(points)
+}
+
+// insert writes the points using multi-row 'INSERT ... ON CONFLICT'
+// statements.
+func (p *Postgres) insert(points []sink.Point) error {
+	columns := 1 + len(p.tags) + len(p.values)
+	chunkSize := maxParams / columns
+
+	for i := 0; i < len(points); i += chunkSize {
+		end := i + chunkSize
+		if end > len(points) {
+			end = len(points)
+		}
+
+		args := []interface{}{}
+		for _, point := range points[i:end] {
+			args = append(args, p.row(point)...)
+		}
+
+		stmt := insertStatement(p.table(), p.tags, p.values, end-i)
+		if _, err := p.DB.Exec(stmt, args...); err != nil {
+			return fmt.Errorf("error while inserting into %s: %s", p.table(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+// copy writes the points into a temporary table using 'COPY' and upserts
+// them from there in a single transaction.
+func (p *Postgres) copy(points []sink.Point) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	staging := "traductio_staging"
+	_, err = tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", pq.QuoteIdentifier(staging), p.table()))
+	if err != nil {
+		return fmt.Errorf("error while creating staging table for %s: %s", p.table(), err.Error())
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(staging, columns(p.tags, p.values)...))
+	if err != nil {
+		return fmt.Errorf("error while preparing copy into %s: %s", p.table(), err.Error())
+	}
+	for _, point := range points {
+		if _, err = stmt.Exec(p.row(point)...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("error while copying into %s: %s", p.table(), err.Error())
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("error while copying into %s: %s", p.table(), err.Error())
+	}
+	if err = stmt.Close(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(upsertFromStatement(p.table(), pq.QuoteIdentifier(staging), p.tags, p.values))
+	if err != nil {
+		return fmt.Errorf("error while upserting into %s: %s", p.table(), err.Error())
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) row(point sink.Point) []interface{} {
+	row := []interface{}{point.Timestamp}
+	for _, t := range p.tags {
+		row = append(row, point.Tags[t])
+	}
+	for _, v := range p.values {
+		if value, ok := point.Values[v]; ok {
+			row = append(row, value)
+		} else {
+			row = append(row, nil)
+		}
+	}
+	return row
+}
+
+// ensureTable creates the table if it does not exist yet and creation is
+// enabled, otherwise it validates that all columns required exist and that
+// the unique constraint the upserts rely on is in place.
+func (p *Postgres) ensureTable(tags, values []string) error {
+	seen := map[string]bool{timeColumn: true}
+	for _, name := range append(append([]string{}, tags...), values...) {
+		if seen[name] {
+			return fmt.Errorf("column '%s' is not unique, tags and values cannot share names or be named '%s'", name, timeColumn)
+		}
+		seen[name] = true
+	}
+
+	rows, err := p.DB.Query("SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2", p.Schema, p.Table)
+	if err != nil {
+		return fmt.Errorf("error while reading columns of %s: %s", p.table(), err.Error())
+	}
+	defer rows.Close()
+
+	existing := map[string]string{}
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return err
+		}
+		existing[name] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		if !p.Create {
+			return fmt.Errorf("table %s does not exist", p.table())
+		}
+		if _, err := p.DB.Exec(createStatement(p.table(), tags, values)); err != nil {
+			return fmt.Errorf("error while creating table %s: %s", p.table(), err.Error())
+		}
+		if p.Hypertable {
+			if _, err := p.DB.Exec("SELECT create_hypertable($1, $2, if_not_exists => TRUE)", p.table(), timeColumn); err != nil {
+				return fmt.Errorf("error while creating hypertable %s: %s", p.table(), err.Error())
+			}
+		}
+		return nil
+	}
+
+	errs := []string{}
+	expect := func(column, dataType string) {
+		if actual, ok := existing[column]; !ok {
+			errs = append(errs, fmt.Sprintf("column '%s' is missing", column))
+		} else if actual != dataType {
+			errs = append(errs, fmt.Sprintf("column '%s' is of type '%s', '%s' was expected", column, actual, dataType))
+		}
+	}
+	expect(timeColumn, "timestamp with time zone")
+	for _, t := range tags {
+		expect(t, "text")
+	}
+	for _, v := range values {
+		expect(v, "double precision")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("table %s does not match the structure of the points: %s", p.table(), strings.Join(errs, ", "))
+	}
+
+	return p.ensureUnique(tags)
+}
+
+// ensureUnique checks that the table has a unique constraint or index on
+// exactly the time and the tags, which 'ON CONFLICT' requires.
+func (p *Postgres) ensureUnique(tags []string) error {
+	rows, err := p.DB.Query(`SELECT array_agg(a.attname::text) FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisunique AND i.indpred IS NULL
+		GROUP BY i.indexrelid`, p.table())
+	if err != nil {
+		return fmt.Errorf("error while reading unique indexes of %s: %s", p.table(), err.Error())
+	}
+	defer rows.Close()
+
+	required := append([]string{timeColumn}, tags...)
+	sort.Strings(required)
+	for rows.Next() {
+		var cols pq.StringArray
+		if err := rows.Scan(&cols); err != nil {
+			return err
+		}
+		sort.Strings(cols)
+		if strings.Join(cols, "\xff") == strings.Join(required, "\xff") {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("table %s has no unique constraint on (%s) which is required to upsert points", p.table(), strings.Join(quote(append([]string{timeColumn}, tags...)), ", "))
+}
+
+func (p *Postgres) table() string {
+	return pq.QuoteIdentifier(p.Schema) + "." + pq.QuoteIdentifier(p.Table)
+}
+
+func (p *Postgres) Close() {
+	p.DB.Close()
+}
+
+func createStatement(table string, tags, values []string) string {
+	defs := []string{pq.QuoteIdentifier(timeColumn) + " TIMESTAMPTZ NOT NULL"}
+	for _, t := range tags {
+		defs = append(defs, pq.QuoteIdentifier(t)+" TEXT NOT NULL DEFAULT ''")
+	}
+	for _, v := range values {
+		defs = append(defs, pq.QuoteIdentifier(v)+" DOUBLE PRECISION")
+	}
+	defs = append(defs, "UNIQUE ("+strings.Join(quote(append([]string{timeColumn}, tags...)), ", ")+")")
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(defs, ", "))
+}
+
+func insertStatement(table string, tags, values []string, rows int) string {
+	cols := columns(tags, values)
+	placeholders := []string{}
+	for r := 0; r < rows; r++ {
+		row := []string{}
+		for c := range cols {
+			row = append(row, fmt.Sprintf("$%d", r*len(cols)+c+1))
+		}
+		placeholders = append(placeholders, "("+strings.Join(row, ", ")+")")
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s %s", table, strings.Join(quote(cols), ", "), strings.Join(placeholders, ", "), onConflict(table, tags, values))
+}
+
+func upsertFromStatement(table, staging string, tags, values []string) string {
+	cols := strings.Join(quote(columns(tags, values)), ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s %s", table, cols, cols, staging, onConflict(table, tags, values))
+}
+
+// onConflict replaces the values of existing rows, values not provided keep
+// their former value.
+func onConflict(table string, tags, values []string) string {
+	target := strings.Join(quote(append([]string{timeColumn}, tags...)), ", ")
+	if len(values) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", target)
+	}
+	sets := []string{}
+	for _, v := range values {
+		q := pq.QuoteIdentifier(v)
+		sets = append(sets, fmt.Sprintf("%s = COALESCE(EXCLUDED.%s, %s.%s)", q, q, table, q))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", target, strings.Join(sets, ", "))
+}
+
+func columns(tags, values []string) []string {
+	cols := append([]string{timeColumn}, tags...)
+	return append(cols, values...)
+}
+
+func quote(names []string) []string {
+	out := []string{}
+	for _, n := range names {
+		out = append(out, pq.QuoteIdentifier(n))
+	}
+	return out
+}
+
+// contains returns an error if not all names are known.
+func contains(known, names []string, kind string) error {
+	k := map[string]bool{}
+	for _, n := range known {
+		k[n] = true
+	}
+	for _, n := range names {
+		if !k[n] {
+			return fmt.Errorf("%s '%s' is not part of the structure prepared", kind, n)
+		}
+	}
+	return nil
+}
+
+// deduplicate merges points with the same time and tags, a single statement
+// cannot upsert the same row twice.
+func deduplicate(points []sink.Point) []sink.Point {
+	index := map[string]int{}
+	out := []sink.Point{}
+	for _, p := range points {
+		key := p.Timestamp.UTC().Format(time.RFC3339Nano)
+		for _, t := range keysOf(p.Tags) {
+			key += "\xff" + t + "\xff" + p.Tags[t]
+		}
+		if i, ok := index[key]; ok {
+			for k, v := range p.Values {
+				out[i].Values[k] = v
+			}
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, p.Copy())
+	}
+	return out
+}
+
+func keysOf(m map[string]string) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
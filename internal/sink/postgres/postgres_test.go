@@ -0,0 +1,227 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestInsertStatement(t *testing.T) {
+	got := insertStatement(`"public"."traffic"`, []string{"host"}, []string{"bytes", "hits"}, 2)
+	expected := `INSERT INTO "public"."traffic" ("time", "host", "bytes", "hits") VALUES ($1, $2, $3, $4), ($5, $6, $7, $8) ` +
+		`ON CONFLICT ("time", "host") DO UPDATE SET "bytes" = COALESCE(EXCLUDED."bytes", "public"."traffic"."bytes"), ` +
+		`"hits" = COALESCE(EXCLUDED."hits", "public"."traffic"."hits")`
+	if got != expected {
+		t.Errorf("statement is not as expected:\n%s\n%s", got, expected)
+	}
+}
+
+func TestCreateStatement(t *testing.T) {
+	got := createStatement(`"public"."traffic"`, []string{"host"}, []string{"bytes"})
+	expected := `CREATE TABLE IF NOT EXISTS "public"."traffic" ("time" TIMESTAMPTZ NOT NULL, "host" TEXT NOT NULL DEFAULT '', ` +
+		`"bytes" DOUBLE PRECISION, UNIQUE ("time", "host"))`
+	if got != expected {
+		t.Errorf("statement is not as expected:\n%s\n%s", got, expected)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	ts := time.Unix(0, 0)
+	points := []sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"bytes": 1}},
+		{Timestamp: ts, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"bytes": 2}},
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 3}},
+	}
+	got := deduplicate(points)
+	if len(got) != 2 {
+		t.Fatalf("2 points were expected, got %d", len(got))
+	}
+	if got[0].Values["bytes"] != 1 || got[0].Values["hits"] != 3 {
+		t.Errorf("values were not merged: %v", got[0].Values)
+	}
+	if _, ok := points[0].Values["hits"]; ok {
+		t.Errorf("original point was modified")
+	}
+}
+
+const (
+	columnsQuery = "SELECT column_name, data_type FROM information_schema.columns"
+	uniqueQuery  = "SELECT array_agg(a.attname::text) FROM pg_index i"
+)
+
+// mock returns a sink for the table "public"."traffic" connected to a mock
+// of the database.
+func mock(t *testing.T) (*Postgres, sqlmock.Sqlmock) {
+	db, m, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Postgres{DB: db, Schema: "public", Table: "traffic", CopyThreshold: defaultCopyThreshold}, m
+}
+
+// expectColumns expects the columns of the table to be read and returns
+// those given as pairs of name and type.
+func expectColumns(m sqlmock.Sqlmock, columns ...string) {
+	rows := sqlmock.NewRows([]string{"column_name", "data_type"})
+	for i := 0; i < len(columns); i += 2 {
+		rows.AddRow(columns[i], columns[i+1])
+	}
+	m.ExpectQuery(regexp.QuoteMeta(columnsQuery)).WithArgs("public", "traffic").WillReturnRows(rows)
+}
+
+// expectUnique expects the unique indexes of the table to be read and
+// returns those given.
+func expectUnique(m sqlmock.Sqlmock, indexes ...[]string) {
+	rows := sqlmock.NewRows([]string{"array_agg"})
+	for _, index := range indexes {
+		rows.AddRow("{" + strings.Join(index, ",") + "}")
+	}
+	m.ExpectQuery(regexp.QuoteMeta(uniqueQuery)).WithArgs(`"public"."traffic"`).WillReturnRows(rows)
+}
+
+func TestPrepare(t *testing.T) {
+	existing := []string{"time", "timestamp with time zone", "host", "text", "bytes", "double precision"}
+
+	tests := []struct {
+		name        string
+		create      bool
+		hypertable  bool
+		tags        []string
+		values      []string
+		expect      func(m sqlmock.Sqlmock)
+		errExpected string
+	}{
+		{
+			name: "create", create: true, hypertable: true, tags: []string{"host"}, values: []string{"bytes"},
+			expect: func(m sqlmock.Sqlmock) {
+				expectColumns(m)
+				m.ExpectExec(regexp.QuoteMeta(createStatement(`"public"."traffic"`, []string{"host"}, []string{"bytes"}))).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectExec(regexp.QuoteMeta("SELECT create_hypertable($1, $2, if_not_exists => TRUE)")).
+					WithArgs(`"public"."traffic"`, "time").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "missing", tags: []string{"host"}, values: []string{"bytes"},
+			expect:      func(m sqlmock.Sqlmock) { expectColumns(m) },
+			errExpected: `table "public"."traffic" does not exist`,
+		},
+		{
+			name: "existing", tags: []string{"host"}, values: []string{"bytes"},
+			expect: func(m sqlmock.Sqlmock) {
+				expectColumns(m, existing...)
+				expectUnique(m, []string{"time"}, []string{"host", "time"})
+			},
+		},
+		{
+			name: "existing_mismatch", tags: []string{"host", "region"}, values: []string{"bytes"},
+			expect: func(m sqlmock.Sqlmock) {
+				expectColumns(m, "time", "timestamp with time zone", "host", "text", "bytes", "bigint")
+			},
+			errExpected: "column 'region' is missing, column 'bytes' is of type 'bigint', 'double precision' was expected",
+		},
+		{
+			name: "existing_without_unique", tags: []string{"host"}, values: []string{"bytes"},
+			expect: func(m sqlmock.Sqlmock) {
+				expectColumns(m, existing...)
+				expectUnique(m, []string{"time"}, []string{"host", "time", "bytes"})
+			},
+			errExpected: `table "public"."traffic" has no unique constraint on ("time", "host")`,
+		},
+		{
+			name: "tag_and_value", tags: []string{"host"}, values: []string{"host"},
+			expect:      func(m sqlmock.Sqlmock) {},
+			errExpected: "column 'host' is not unique",
+		},
+		{
+			name: "time", tags: []string{"time"}, values: []string{"bytes"},
+			expect:      func(m sqlmock.Sqlmock) {},
+			errExpected: "column 'time' is not unique",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, m := mock(t)
+			p.Create, p.Hypertable = test.create, test.hypertable
+			test.expect(m)
+
+			err := p.Prepare(test.tags, test.values)
+			if test.errExpected == "" && err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			} else if test.errExpected != "" && (err == nil || !strings.Contains(err.Error(), test.errExpected)) {
+				t.Fatalf("error containing '%s' was expected, error was '%v'", test.errExpected, err)
+			}
+			if err := m.ExpectationsWereMet(); err != nil {
+				t.Errorf("statements are not as expected: %s", err)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ts := time.Unix(1638316800, 0)
+	points := []sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"bytes": 1}},
+		{Timestamp: ts, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"bytes": 2}},
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 3}},
+	}
+	table := `"public"."traffic"`
+	tags, values := []string{"host"}, []string{"bytes", "hits"}
+
+	tests := []struct {
+		name      string
+		threshold int
+		expect    func(m sqlmock.Sqlmock)
+	}{
+		{
+			name:      "insert",
+			threshold: defaultCopyThreshold,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(regexp.QuoteMeta(insertStatement(table, tags, values, 2))).
+					WithArgs(ts, "a", 1.0, 3.0, ts, "b", 2.0, nil).WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+		},
+		{
+			name:      "copy",
+			threshold: 2,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(`CREATE TEMP TABLE "traductio_staging" (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP`, table))).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				copyIn := m.ExpectPrepare(regexp.QuoteMeta(pq.CopyIn("traductio_staging", columns(tags, values)...)))
+				copyIn.ExpectExec().WithArgs(ts, "a", 1.0, 3.0).WillReturnResult(sqlmock.NewResult(0, 0))
+				copyIn.ExpectExec().WithArgs(ts, "b", 2.0, nil).WillReturnResult(sqlmock.NewResult(0, 0))
+				copyIn.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+				copyIn.WillBeClosed()
+				m.ExpectExec(regexp.QuoteMeta(upsertFromStatement(table, `"traductio_staging"`, tags, values))).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				m.ExpectCommit()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, m := mock(t)
+			p.CopyThreshold = test.threshold
+			expectColumns(m, "time", "timestamp with time zone", "host", "text", "bytes", "double precision", "hits", "double precision")
+			expectUnique(m, []string{"time", "host"})
+			test.expect(m)
+
+			if err := p.Write(points); err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if err := m.ExpectationsWereMet(); err != nil {
+				t.Errorf("statements are not as expected: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func init() {
+	sink.Register("remote_write", setup)
+}
+
+const (
+	defaultBatchSize = 500
+	defaultTimeout   = 30 * time.Second
+)
+
+// RemoteWrite sends points to a receiver implementing the Prometheus remote
+// write protocol such as Prometheus, Mimir or VictoriaMetrics. Each value of
+// a point is sent as sample of the series '<series>_<value key>' with the
+// tags of the point as labels.
+type RemoteWrite struct {
+	Client      *http.Client
+	URL         string
+	Series      string
+	BatchSize   int
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+type timeSeries struct {
+	labels  []label
+	samples []sample
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	r := RemoteWrite{
+		BatchSize: defaultBatchSize,
+	}
+
+	var found bool
+	var err error
+
+	if r.URL, found = config["url"]; !found || r.URL == "" {
+		return r, fmt.Errorf("sink requires field 'url' to be set")
+	}
+	if r.Series, found = config["series"]; !found || r.Series == "" {
+		return r, fmt.Errorf("sink requires field 'series' to be set")
+	}
+	if batchSize, found := config["batch_size"]; found && batchSize != "" {
+		if r.BatchSize, err = strconv.Atoi(batchSize); err != nil || r.BatchSize < 1 {
+			return r, fmt.Errorf("sink field 'batch_size' must be a positive number")
+		}
+	}
+	timeout := defaultTimeout
+	if t, found := config["timeout"]; found && t != "" {
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return r, fmt.Errorf("sink field 'timeout' must be a duration: %s", err.Error())
+		}
+	}
+
+	r.Username = config["username"]
+	r.Password = config["password"]
+	r.BearerToken = config["bearer_token"]
+	if r.BearerToken != "" && r.Username != "" {
+		return r, fmt.Errorf("sink fields 'username' and 'bearer_token' cannot be used together")
+	}
+
+	r.Client = &http.Client{Timeout: timeout}
+	return r, nil
+}
+
+func (r RemoteWrite) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	series, err := r.timeSeries(points)
+	if err != nil {
+		return err
+	}
+
+	batch := []timeSeries{}
+	samples := 0
+	for _, ts := range series {
+		// series are split if they exceed the batch size on their own
+		for len(ts.samples) > 0 {
+			n := r.BatchSize - samples
+			if n > len(ts.samples) {
+				n = len(ts.samples)
+			}
+			batch = append(batch, timeSeries{labels: ts.labels, samples: ts.samples[:n]})
+			ts.samples = ts.samples[n:]
+			samples += n
+
+			if samples >= r.BatchSize {
+				if err := r.send(batch); err != nil {
+					return err
+				}
+				batch = []timeSeries{}
+				samples = 0
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return r.send(batch)
+	}
+
+	return nil
+}
+
+// timeSeries groups the values of the points by series and labels. Tags
+// without value are dropped as Prometheus rejects empty labels, tags which
+// end up with the same label name after sanitizing are rejected.
+func (r RemoteWrite) timeSeries(points []sink.Point) ([]timeSeries, error) {
+	index := map[string]int{}
+	series := []timeSeries{}
+
+	for _, p := range points {
+		keys := []string{}
+		for k, v := range p.Tags {
+			if v != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		tags := []label{}
+		names := map[string]string{}
+		for _, k := range keys {
+			name := sanitize(k, false)
+			if name == "__name__" {
+				return nil, fmt.Errorf("tag '%s' of the point at %s collides with the metric name label '__name__'", k, p.Timestamp)
+			}
+			if other, ok := names[name]; ok {
+				return nil, fmt.Errorf("tags '%s' and '%s' of the point at %s are both sanitized to the label name '%s'", other, k, p.Timestamp, name)
+			}
+			names[name] = k
+			tags = append(tags, label{name: name, value: p.Tags[k]})
+		}
+
+		for k, v := range p.Values {
+			labels := append([]label{{name: "__name__", value: sanitize(r.Series+"_"+k, true)}}, tags...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+			key := ""
+			for _, l := range labels {
+				key += l.name + "\xff" + l.value + "\xff"
+			}
+
+			i, ok := index[key]
+			if !ok {
+				i = len(series)
+				index[key] = i
+				series = append(series, timeSeries{labels: labels})
+			}
+			series[i].samples = append(series[i].samples, sample{value: v, timestamp: p.Timestamp.UnixMilli()})
+		}
+	}
+
+	for _, ts := range series {
+		sort.SliceStable(ts.samples, func(i, j int) bool { return ts.samples[i].timestamp < ts.samples[j].timestamp })
+	}
+
+	return series, nil
+}
+
+func (r RemoteWrite) send(batch []timeSeries) error {
+	body := snappy.Encode(nil, encode(batch))
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error while creating request: %s", err.Error())
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "traductio")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	if r.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.BearerToken)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while sending to %s: %s", r.URL, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned HTTP status code %d: %s", r.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// encode returns the protobuf encoded prometheus.WriteRequest.
+func encode(batch []timeSeries) []byte {
+	var req []byte
+	for _, ts := range batch {
+		var series []byte
+		for _, l := range ts.labels {
+			var b []byte
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendString(b, l.name)
+			b = protowire.AppendTag(b, 2, protowire.BytesType)
+			b = protowire.AppendString(b, l.value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, b)
+		}
+		for _, s := range ts.samples {
+			var b []byte
+			b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(s.value))
+			b = protowire.AppendTag(b, 2, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(s.timestamp))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, b)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, series)
+	}
+	return req
+}
+
+// sanitize replaces all characters not allowed in metric or label names
+// with underscores.
+func sanitize(name string, metric bool) string {
+	out := []rune{}
+	for i, c := range name {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (metric && c == ':') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			c = '_'
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+func (r RemoteWrite) Close() {
+}
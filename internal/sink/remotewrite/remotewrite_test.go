@@ -0,0 +1,229 @@
+package remotewrite
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields calls f for each field of the protobuf message b.
+func fields(t *testing.T, b []byte, f func(num protowire.Number, v []byte, n uint64)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("could not parse tag: %s", protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			f(num, v, 0)
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			f(num, nil, v)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			f(num, nil, v)
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+}
+
+// decode parses a prometheus.WriteRequest into a map of series identified by
+// their labels holding their samples as '<unix timestamp>=<value>' strings.
+func decode(t *testing.T, req []byte, out map[string][]string) {
+	fields(t, req, func(_ protowire.Number, series []byte, _ uint64) {
+		labels := []string{}
+		samples := []string{}
+		fields(t, series, func(num protowire.Number, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				l := []string{}
+				fields(t, v, func(_ protowire.Number, v []byte, _ uint64) { l = append(l, string(v)) })
+				labels = append(labels, strings.Join(l, "="))
+			case 2:
+				var value float64
+				var ts int64
+				fields(t, v, func(num protowire.Number, _ []byte, n uint64) {
+					if num == 1 {
+						value = math.Float64frombits(n)
+					} else {
+						ts = int64(n)
+					}
+				})
+				samples = append(samples, strconv.FormatInt(ts/1000, 10)+"="+strconv.FormatFloat(value, 'f', -1, 64))
+			}
+		})
+		if !sort.StringsAreSorted(labels) {
+			t.Errorf("labels are not sorted: %v", labels)
+		}
+		key := strings.Join(labels, ",")
+		out[key] = append(out[key], samples...)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := 0
+	received := map[string][]string{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+
+		if u, p, ok := r.BasicAuth(); !ok || u != "traductio" || p != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		compressed, _ := ioutil.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("could not decompress body: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		decode(t, body, received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	points := []sink.Point{
+		{
+			Timestamp: time.Unix(20, 0),
+			Tags:      map[string]string{"domain": "www.example.com"},
+			Values:    map[string]float64{"requests": 3, "bytes.sent": 1024},
+		},
+		{
+			Timestamp: time.Unix(10, 0),
+			Tags:      map[string]string{"domain": "www.example.com"},
+			Values:    map[string]float64{"requests": 2, "bytes.sent": 512.5},
+		},
+		{
+			Timestamp: time.Unix(10, 0),
+			Tags:      map[string]string{"domain": "api.example.com"},
+			Values:    map[string]float64{"requests": 1},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		errExpected bool
+		requests    int
+	}{
+		{
+			name:     "single_batch",
+			config:   map[string]string{"url": ts.URL, "series": "access", "username": "traductio", "password": "s3cr3t"},
+			requests: 1,
+		},
+		{
+			name:     "multiple_batches",
+			config:   map[string]string{"url": ts.URL, "series": "access", "username": "traductio", "password": "s3cr3t", "batch_size": "2"},
+			requests: 3,
+		},
+		{
+			name:        "unauthorized",
+			config:      map[string]string{"url": ts.URL, "series": "access", "bearer_token": "wrong"},
+			errExpected: true,
+			requests:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests = 0
+			received = map[string][]string{}
+
+			s, err := setup(test.config)
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			defer s.Close()
+
+			err = s.Write(points)
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if requests != test.requests {
+				t.Errorf("%d requests were expected, %d were issued", test.requests, requests)
+			}
+			if test.errExpected {
+				return
+			}
+
+			expected := map[string][]string{
+				"__name__=access_requests,domain=www.example.com":   {"10=2", "20=3"},
+				"__name__=access_bytes_sent,domain=www.example.com": {"10=512.5", "20=1024"},
+				"__name__=access_requests,domain=api.example.com":   {"10=1"},
+			}
+			if !reflect.DeepEqual(received, expected) {
+				t.Errorf("series received are not as expected: %v", received)
+			}
+		})
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	r := RemoteWrite{Series: "access"}
+	ts := time.Unix(10, 0)
+
+	tests := []struct {
+		name        string
+		tags        map[string]string
+		errExpected bool
+		expected    []label
+	}{
+		{
+			name:     "empty_value",
+			tags:     map[string]string{"domain": "www.example.com", "path": ""},
+			expected: []label{{name: "__name__", value: "access_requests"}, {name: "domain", value: "www.example.com"}},
+		},
+		{
+			name:     "empty_value_same_name",
+			tags:     map[string]string{"status.code": "200", "status_code": ""},
+			expected: []label{{name: "__name__", value: "access_requests"}, {name: "status_code", value: "200"}},
+		},
+		{name: "same_name", tags: map[string]string{"status.code": "200", "status_code": "404"}, errExpected: true},
+		{name: "metric_name", tags: map[string]string{"__name__": "up"}, errExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := r.timeSeries([]sink.Point{{Timestamp: ts, Tags: test.tags, Values: map[string]float64{"requests": 1}}})
+			if err == nil && test.errExpected {
+				t.Fatalf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if test.errExpected {
+				return
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0].labels, test.expected) {
+				t.Errorf("labels are not as expected: %v", got)
+			}
+		})
+	}
+}
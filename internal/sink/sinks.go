@@ -10,11 +10,21 @@ var (
 	s   = make(map[string]func(map[string]string) (Sink, error))
 )
 
+const (
+	// PolicyRequired fails the whole run if the sink cannot be written.
+	PolicyRequired = "required"
+	// PolicyBestEffort reports errors of the sink but does not fail the run.
+	PolicyBestEffort = "best_effort"
+)
+
 // Config keeps the configuration of the sink implementation. This struct is
 // passed to the sink implementatinon itself via the registered setup function.
-// The field 'Kind' is used to determine which provider is requested.
+// The field 'Kind' is used to determine which provider is requested. The
+// optional fields 'Name' and 'Policy' are used when writing to multiple sinks.
 type Config struct {
+	Name       string            `yaml:"name,omitempty"`
 	Kind       string            `yaml:"kind"`
+	Policy     string            `yaml:"policy,omitempty"`
 	Connection map[string]string `yaml:"connection"`
 }
 
@@ -23,6 +33,9 @@ func (c Config) Validate() ([]string, error) {
 	if c.Kind == "" {
 		errs = append(errs, "Field 'kind' cannot be empty.")
 	}
+	if c.Policy != "" && c.Policy != PolicyRequired && c.Policy != PolicyBestEffort {
+		errs = append(errs, "Field 'policy' must be either '"+PolicyRequired+"' or '"+PolicyBestEffort+"'.")
+	}
 	if len(errs) > 0 {
 		err := errors.New("Config of 'sink' has errors")
 		return errs, err
@@ -38,6 +51,13 @@ type Sink interface {
 	//Query(cmd string) (res []client.Result, err error)
 }
 
+// Preparer can be implemented by sinks which need to know the structure of
+// the points before they are written, for example to create tables. The
+// tags and values passed are sorted and unique.
+type Preparer interface {
+	Prepare(tags, values []string) error
+}
+
 // Register must be called in the init function of each sink implementation.
 // The Register function will panic if two sink impelmentations with the
 // same name try to register themselves.
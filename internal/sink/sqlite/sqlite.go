@@ -0,0 +1,247 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"traductio/internal/sink"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	sink.Register("sqlite", setup)
+}
+
+const (
+	timeColumn = "time"
+	// timestamps are stored as text with a fixed width to keep them sortable
+	timeFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+// SQLite writes points into a wide table of a SQLite database file. The table
+// has a column 'time', a text column per tag and a real column per value.
+// Columns are added as soon as new tags or values appear, points with the same
+// time and tags are upserted.
+type SQLite struct {
+	DB    *sql.DB
+	Table string
+
+	tags    []string
+	values  []string
+	checked bool
+	exists  bool
+}
+
+func setup(config map[string]string) (sink.Sink, error) {
+	s := &SQLite{}
+
+	var found bool
+	var path string
+	var err error
+
+	if path, found = config["path"]; !found || path == "" {
+		return s, fmt.Errorf("sink requires field 'path' to be set")
+	}
+	if s.Table, found = config["series"]; !found || s.Table == "" {
+		return s, fmt.Errorf("sink requires field 'series' to be set")
+	}
+
+	s.DB, err = sql.Open("sqlite", path)
+	if err != nil {
+		return s, err
+	}
+	// a single connection avoids 'database is locked' errors within a run
+	s.DB.SetMaxOpenConns(1)
+
+	return s, nil
+}
+
+// Prepare creates the table or adds the columns missing.
+func (s *SQLite) Prepare(tags, values []string) error {
+	return s.evolve(tags, values)
+}
+
+func (s *SQLite) Write(points []sink.Point) error {
+	if len(points) < 1 {
+		return fmt.Errorf("no points to be written")
+	}
+
+	err := s.evolve(sink.Structure(points))
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsertStatement(s.Table, s.tags, s.values))
+	if err != nil {
+		return fmt.Errorf("error while preparing upsert into %s: %s", s.Table, err.Error())
+	}
+	defer stmt.Close()
+
+	for _, point := range points {
+		row := []interface{}{point.Timestamp.UTC().Format(timeFormat)}
+		for _, t := range s.tags {
+			row = append(row, point.Tags[t])
+		}
+		for _, v := range s.values {
+			if value, ok := point.Values[v]; ok {
+				row = append(row, value)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		if _, err = stmt.Exec(row...); err != nil {
+			return fmt.Errorf("error while upserting into %s: %s", s.Table, err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+// evolve creates the table if it does not exist yet and adds columns for
+// all tags and values which are not yet part of the table. Adding a tag
+// recreates the unique index on time and tags which is required to upsert.
+func (s *SQLite) evolve(tags, values []string) error {
+	for _, name := range append(append([]string{}, tags...), values...) {
+		if name == timeColumn {
+			return fmt.Errorf("tags and values cannot be named '%s'", timeColumn)
+		}
+	}
+
+	if !s.checked {
+		err := s.readColumns()
+		if err != nil {
+			return err
+		}
+		s.checked = true
+	}
+
+	newTags := missing(s.tags, tags)
+	newValues := missing(s.values, values)
+	if len(newTags) == 0 && len(newValues) == 0 {
+		return nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL)", quote(s.Table), quote(timeColumn)),
+	}
+	for _, t := range newTags {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT NOT NULL DEFAULT ''", quote(s.Table), quote(t)))
+	}
+	for _, v := range newValues {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s REAL", quote(s.Table), quote(v)))
+	}
+	allTags := append(append([]string{}, s.tags...), newTags...)
+	if len(newTags) > 0 || !s.exists {
+		index := quote(s.Table + "_time_tags")
+		stmts = append(stmts,
+			fmt.Sprintf("DROP INDEX IF EXISTS %s", index),
+			fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", index, quote(s.Table), strings.Join(quoteAll(append([]string{timeColumn}, allTags...)), ", ")),
+		)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error while evolving table %s: %s", s.Table, err.Error())
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.exists = true
+	s.tags = allTags
+	s.values = append(s.values, newValues...)
+	return nil
+}
+
+// readColumns reads the tag and value columns of an existing table.
+func (s *SQLite) readColumns() error {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", quote(s.Table)))
+	if err != nil {
+		return fmt.Errorf("error while reading columns of %s: %s", s.Table, err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, dataType string
+		var def sql.NullString
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &def, &pk); err != nil {
+			return err
+		}
+		s.exists = true
+		switch {
+		case name == timeColumn:
+		case strings.EqualFold(dataType, "TEXT"):
+			s.tags = append(s.tags, name)
+		case strings.EqualFold(dataType, "REAL"):
+			s.values = append(s.values, name)
+		default:
+			return fmt.Errorf("column '%s' of table %s is of unsupported type '%s'", name, s.Table, dataType)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLite) Close() {
+	s.DB.Close()
+}
+
+func upsertStatement(table string, tags, values []string) string {
+	cols := append(append([]string{timeColumn}, tags...), values...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	target := strings.Join(quoteAll(append([]string{timeColumn}, tags...)), ", ")
+
+	conflict := "DO NOTHING"
+	if len(values) > 0 {
+		sets := []string{}
+		for _, v := range values {
+			q := quote(v)
+			sets = append(sets, fmt.Sprintf("%s = COALESCE(excluded.%s, %s)", q, q, q))
+		}
+		conflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		quote(table), strings.Join(quoteAll(cols), ", "), placeholders, target, conflict)
+}
+
+// missing returns the names which are not known yet.
+func missing(known, names []string) []string {
+	k := map[string]bool{}
+	for _, n := range known {
+		k[n] = true
+	}
+	out := []string{}
+	for _, n := range names {
+		if !k[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func quoteAll(names []string) []string {
+	out := []string{}
+	for _, n := range names {
+		out = append(out, quote(n))
+	}
+	return out
+}
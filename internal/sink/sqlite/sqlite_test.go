@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+)
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.db")
+	ts := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := setup(map[string]string{"path": path, "series": "traffic"})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	err = s.Write([]sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"bytes": 1}},
+		{Timestamp: ts, Tags: map[string]string{"host": "b"}, Values: map[string]float64{"bytes": 2}},
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	s.Close()
+
+	// a second run with a new tag and a new value evolves the schema and
+	// upserts the existing point
+	s, err = setup(map[string]string{"path": path, "series": "traffic"})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	defer s.Close()
+	err = s.Write([]sink.Point{
+		{Timestamp: ts, Tags: map[string]string{"host": "a"}, Values: map[string]float64{"hits": 3}},
+		{Timestamp: ts, Tags: map[string]string{"host": "a", "zone": "x"}, Values: map[string]float64{"bytes": 4}},
+	})
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+
+	rows, err := s.(*SQLite).DB.Query(`SELECT "time", "host", "zone", "bytes", "hits" FROM "traffic" ORDER BY "host", "zone"`)
+	if err != nil {
+		t.Fatalf("no error was expected, error was '%s'", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		Time, Host, Zone string
+		Bytes, Hits      *float64
+	}
+	got := []row{}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.Time, &r.Host, &r.Zone, &r.Bytes, &r.Hits); err != nil {
+			t.Fatalf("no error was expected, error was '%s'", err)
+		}
+		got = append(got, r)
+	}
+
+	f := func(v float64) *float64 { return &v }
+	expected := []row{
+		{Time: "2021-12-01T00:00:00.000000000Z", Host: "a", Zone: "", Bytes: f(1), Hits: f(3)},
+		{Time: "2021-12-01T00:00:00.000000000Z", Host: "a", Zone: "x", Bytes: f(4)},
+		{Time: "2021-12-01T00:00:00.000000000Z", Host: "b", Zone: "", Bytes: f(2)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("rows are not as expected")
+		for _, r := range got {
+			t.Logf("%s %s %s %v %v", r.Time, r.Host, r.Zone, r.Bytes, r.Hits)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package storage reads and writes the files of file based sinks, which are
+// either local files or objects on S3 if the path is an URL with the scheme
+// 's3'.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Read returns the content of the file or object, which is empty if it does
+// not exist yet.
+func Read(path string) ([]byte, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	switch u.Scheme {
+	case "":
+		return readFile(u.Path)
+	case "s3":
+		return readS3(u.Host, strings.TrimPrefix(u.Path, "/"))
+	default:
+		return []byte{}, fmt.Errorf("cannot read %s: unsupported protocol %s", path, u.Scheme)
+	}
+}
+
+// Write replaces the content of the file or object with data or appends data
+// to it. Objects on S3 cannot be appended to, they are read, extended and
+// written again.
+func Write(path string, data []byte, appending bool) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "":
+		return writeFile(u.Path, data, appending)
+	case "s3":
+		bucket, object := u.Host, strings.TrimPrefix(u.Path, "/")
+		if appending {
+			existing, err := readS3(bucket, object)
+			if err != nil {
+				return err
+			}
+			data = append(existing, data...)
+		}
+		return writeS3(bucket, object, data)
+	default:
+		return fmt.Errorf("cannot write %s: unsupported protocol %s", path, u.Scheme)
+	}
+}
+
+// Join joins the elements to a path, keeping the scheme and the bucket of
+// S3 URLs intact.
+func Join(base string, elem ...string) string {
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" {
+		u.Path = "/" + strings.TrimPrefix(filepath.ToSlash(filepath.Join(append([]string{u.Path}, elem...)...)), "/")
+		return u.String()
+	}
+	return filepath.Join(append([]string{base}, elem...)...)
+}
+
+func readFile(path string) ([]byte, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return []byte{}, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+	return data, err
+}
+
+func writeFile(path string, data []byte, appending bool) error {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appending {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("error while opening %s: %s", path, err.Error())
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("error while writing %s: %s", path, err.Error())
+	}
+	return file.Close()
+}
+
+func readS3(bucket, object string) ([]byte, error) {
+	ctx := context.Background()
+	awscfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return []byte{}, fmt.Errorf("error while creating s3 client to read %s from %s: %s", object, bucket, err.Error())
+	}
+
+	result, err := s3.NewFromConfig(awscfg).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+	})
+	var notFound *types.NoSuchKey
+	if errors.As(err, &notFound) {
+		return []byte{}, nil
+	} else if err != nil {
+		return []byte{}, fmt.Errorf("error while reading object %s from %s: %s", object, bucket, err.Error())
+	}
+	defer result.Body.Close()
+	return ioutil.ReadAll(result.Body)
+}
+
+func writeS3(bucket, object string, data []byte) error {
+	ctx := context.Background()
+	awscfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("error while creating s3 client to write %s to %s: %s", object, bucket, err.Error())
+	}
+
+	_, err = s3.NewFromConfig(awscfg).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing object %s to %s: %s", object, bucket, err.Error())
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ package timestream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	"traductio/internal/sink"
 
@@ -21,15 +25,121 @@ func init() {
 	sink.Register("timestream", setup)
 }
 
+const (
+	// RecordModeSingle writes one record per value of a point.
+	RecordModeSingle = "single"
+	// RecordModeMulti writes one record holding all values per point.
+	RecordModeMulti = "multi"
+
+	// VersionWallclock uses the time of the write as version of all records.
+	VersionWallclock = "wallclock"
+	// VersionVar uses the rendered 'version_value' as version of all records.
+	VersionVar = "var"
+	// VersionPoint uses the value 'version_key' of each point as version.
+	VersionPoint = "point"
+
+	valueTypePrefix = "type."
+)
+
+var timeUnits = map[string]struct {
+	unit     types.TimeUnit
+	duration time.Duration
+}{
+	"s":  {types.TimeUnitSeconds, time.Second},
+	"ms": {types.TimeUnitMilliseconds, time.Millisecond},
+	"us": {types.TimeUnitMicroseconds, time.Microsecond},
+	"ns": {types.TimeUnitNanoseconds, time.Nanosecond},
+}
+
+// Timestream writes points to AWS Timestream. In record mode 'single' each
+// value is written as record with the value key as measure name, in record
+// mode 'multi' each point is written as one record named after 'MeasureName'.
+// Values are written as DOUBLE unless another type is configured for the
+// value key.
 type Timestream struct {
 	DB          string
-	WriteClient *timestreamwrite.Client
+	WriteClient RecordWriter
 	QueryClient *timestreamquery.Client
 	Series      string
+	RecordMode  string
+	MeasureName string
+	TimeUnit    types.TimeUnit
+	ValueTypes  map[string]types.MeasureValueType
+
+	// Version is the strategy the record versions are determined with,
+	// 'VersionValue' is used for 'VersionVar', 'VersionKey' for
+	// 'VersionPoint'
+	Version      string
+	VersionValue int64
+	VersionKey   string
+
+	// IgnoreVersionConflicts treats records rejected because a record with
+	// the same or a higher version exists as written, which allows to re-run
+	// imports
+	IgnoreVersionConflicts bool
+
+	unit time.Duration
+}
+
+// RecordWriter writes records to Timestream, it is implemented by the
+// timestreamwrite client.
+type RecordWriter interface {
+	WriteRecords(ctx context.Context, params *timestreamwrite.WriteRecordsInput, optFns ...func(*timestreamwrite.Options)) (*timestreamwrite.WriteRecordsOutput, error)
+}
+
+// Rejection describes a record Timestream did not accept along with the
+// point the record originates from.
+type Rejection struct {
+	Chunk           int
+	Record          int
+	Point           sink.Point
+	Measure         string
+	Reason          string
+	ExistingVersion int64
+}
+
+func (r Rejection) String() string {
+	tags := []string{}
+	for _, k := range sortedKeys(r.Point.Tags) {
+		tags = append(tags, k+"="+r.Point.Tags[k])
+	}
+	return fmt.Sprintf("chunk %d record %d (%s at %s with %s): %s", r.Chunk, r.Record, r.Measure,
+		r.Point.Timestamp.Format(time.RFC3339Nano), strings.Join(tags, ","), r.Reason)
+}
+
+// WriteError reports all chunks which could not be written as well as all
+// records rejected by Timestream.
+type WriteError struct {
+	Chunks     int
+	Failed     map[int]error
+	Rejections []Rejection
+}
+
+func (e WriteError) Error() string {
+	msgs := []string{}
+	chunks := []int{}
+	for c := range e.Failed {
+		chunks = append(chunks, c)
+	}
+	sort.Ints(chunks)
+	for _, c := range chunks {
+		msgs = append(msgs, fmt.Sprintf("chunk %d failed: %s", c, e.Failed[c].Error()))
+	}
+	for _, r := range e.Rejections {
+		msgs = append(msgs, r.String())
+	}
+	return fmt.Sprintf("%d of %d chunks failed and %d records were rejected: %s",
+		len(e.Failed), e.Chunks, len(e.Rejections), strings.Join(msgs, "; "))
 }
 
 func setup(c map[string]string) (sink.Sink, error) {
-	t := Timestream{}
+	t := Timestream{
+		RecordMode: RecordModeSingle,
+		Version:    VersionWallclock,
+		TimeUnit:   types.TimeUnitSeconds,
+		ValueTypes: map[string]types.MeasureValueType{},
+		unit:       time.Second,
+	}
 
 	var db, series, region string
 	var found bool
@@ -43,6 +153,57 @@ func setup(c map[string]string) (sink.Sink, error) {
 	if region, found = c["region"]; !found || region == "" {
 		return t, fmt.Errorf("sink requires field 'region' to be set")
 	}
+	if mode, found := c["record_mode"]; found && mode != "" {
+		t.RecordMode = mode
+	}
+	if t.RecordMode != RecordModeSingle && t.RecordMode != RecordModeMulti {
+		return t, fmt.Errorf("sink field 'record_mode' must be either '%s' or '%s'", RecordModeSingle, RecordModeMulti)
+	}
+	t.MeasureName = series
+	if name, found := c["measure_name"]; found && name != "" {
+		t.MeasureName = name
+	}
+	if unit, found := c["time_unit"]; found && unit != "" {
+		u, found := timeUnits[unit]
+		if !found {
+			return t, fmt.Errorf("sink field 'time_unit' must be one of 's', 'ms', 'us' or 'ns'")
+		}
+		t.TimeUnit, t.unit = u.unit, u.duration
+	}
+	if version, found := c["version"]; found && version != "" {
+		t.Version = version
+	}
+	switch t.Version {
+	case VersionWallclock:
+	case VersionVar:
+		var err error
+		if t.VersionValue, err = strconv.ParseInt(c["version_value"], 10, 64); err != nil || t.VersionValue < 1 {
+			return t, fmt.Errorf("sink with version '%s' requires field 'version_value' to be a positive number", VersionVar)
+		}
+	case VersionPoint:
+		if t.VersionKey = c["version_key"]; t.VersionKey == "" {
+			return t, fmt.Errorf("sink with version '%s' requires field 'version_key' to be set", VersionPoint)
+		}
+	default:
+		return t, fmt.Errorf("sink field 'version' must be one of '%s', '%s' or '%s'", VersionWallclock, VersionVar, VersionPoint)
+	}
+	if ignore, found := c["ignore_version_conflicts"]; found && ignore != "" {
+		var err error
+		if t.IgnoreVersionConflicts, err = strconv.ParseBool(ignore); err != nil {
+			return t, fmt.Errorf("sink field 'ignore_version_conflicts' must be either 'true' or 'false'")
+		}
+	}
+	for k, v := range c {
+		if !strings.HasPrefix(k, valueTypePrefix) {
+			continue
+		}
+		switch vt := types.MeasureValueType(strings.ToUpper(v)); vt {
+		case types.MeasureValueTypeDouble, types.MeasureValueTypeBigint, types.MeasureValueTypeBoolean, types.MeasureValueTypeVarchar:
+			t.ValueTypes[strings.TrimPrefix(k, valueTypePrefix)] = vt
+		default:
+			return t, fmt.Errorf("sink field '%s' must be one of 'DOUBLE', 'BIGINT', 'BOOLEAN' or 'VARCHAR'", k)
+		}
+	}
 
 	tr := &http.Transport{
 		ResponseHeaderTimeout: 20 * time.Second,
@@ -79,55 +240,189 @@ func (t Timestream) Write(points []sink.Point) error {
 		return fmt.Errorf("no points to be written")
 	}
 
-	version := time.Now().Round(time.Millisecond).UnixNano()
+	records, origins, err := t.records(points, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// all chunks are written even if some fail, the errors are reported
+	// at once
+	chunkSize := 100
+	report := WriteError{Failed: map[int]error{}}
+	for i := 0; i < len(records); i += chunkSize {
+		end := i + chunkSize
+
+		if end > len(records) {
+			end = len(records)
+		}
+		report.Chunks++
+
+		chunk := &timestreamwrite.WriteRecordsInput{
+			DatabaseName: aws.String(t.DB),
+			TableName:    aws.String(t.Series),
+			Records:      records[i:end],
+		}
+
+		_, err := t.WriteClient.WriteRecords(context.TODO(), chunk)
+		var rejected *types.RejectedRecordsException
+		if errors.As(err, &rejected) {
+			for _, r := range rejected.RejectedRecords {
+				index := i + int(r.RecordIndex)
+				if index >= end {
+					continue
+				}
+				if t.IgnoreVersionConflicts && r.ExistingVersion != 0 {
+					continue
+				}
+				report.Rejections = append(report.Rejections, Rejection{
+					Chunk:           report.Chunks,
+					Record:          int(r.RecordIndex),
+					Point:           points[origins[index]],
+					Measure:         aws.ToString(records[index].MeasureName),
+					Reason:          aws.ToString(r.Reason),
+					ExistingVersion: r.ExistingVersion,
+				})
+			}
+		} else if err != nil {
+			report.Failed[report.Chunks] = err
+		}
+	}
+
+	if len(report.Failed) > 0 || len(report.Rejections) > 0 {
+		return report
+	}
+	return nil
+}
+
+// records returns the records of the points according to the record mode
+// along with the index of the point each record originates from.
+func (t Timestream) records(points []sink.Point, now time.Time) ([]types.Record, []int, error) {
 	records := []types.Record{}
-	for _, p := range points {
+	origins := []int{}
+	for i, p := range points {
+		version, err := t.version(p, now)
+		if err != nil {
+			return records, origins, err
+		}
+
 		// get dimensions from tags
 		dimensions := []types.Dimension{}
-		for k, v := range p.Tags {
+		for _, k := range sortedKeys(p.Tags) {
 			d := types.Dimension{
 				Name:  aws.String(k),
-				Value: aws.String(v),
+				Value: aws.String(p.Tags[k]),
 			}
 			dimensions = append(dimensions, d)
 		}
 
+		// the value used as version is not written as measure
+		keys := []string{}
+		for k := range p.Values {
+			if t.Version == VersionPoint && k == t.VersionKey {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if len(keys) < 1 {
+			continue
+		}
+
+		timestamp := aws.String(strconv.FormatInt(p.Timestamp.UnixNano()/int64(t.unit), 10))
+
+		if t.RecordMode == RecordModeMulti {
+			measures := []types.MeasureValue{}
+			for _, k := range keys {
+				vt := t.valueType(k)
+				measures = append(measures, types.MeasureValue{
+					Name:  aws.String(k),
+					Value: aws.String(formatValue(p.Values[k], vt)),
+					Type:  vt,
+				})
+			}
+			records = append(records, types.Record{
+				Version:          version,
+				Dimensions:       dimensions,
+				MeasureName:      aws.String(t.MeasureName),
+				MeasureValues:    measures,
+				MeasureValueType: types.MeasureValueTypeMulti,
+				Time:             timestamp,
+				TimeUnit:         t.TimeUnit,
+			})
+			origins = append(origins, i)
+			continue
+		}
+
 		// get records
-		for k, v := range p.Values {
+		for _, k := range keys {
+			vt := t.valueType(k)
 			r := types.Record{
 				Version:          version,
 				Dimensions:       dimensions,
 				MeasureName:      aws.String(k),
-				MeasureValue:     aws.String(fmt.Sprintf("%v", v)),
-				MeasureValueType: "DOUBLE",
-				Time:             aws.String(strconv.FormatInt(p.Copy().Timestamp.Unix(), 10)),
-				TimeUnit:         "SECONDS",
+				MeasureValue:     aws.String(formatValue(p.Values[k], vt)),
+				MeasureValueType: vt,
+				Time:             timestamp,
+				TimeUnit:         t.TimeUnit,
 			}
 			records = append(records, r)
+			origins = append(origins, i)
 		}
 	}
+	return records, origins, nil
+}
 
-	chunkSize := 100
-	for i := 0; i < len(records); i += chunkSize {
-		end := i + chunkSize
-
-		if end > len(records) {
-			end = len(records)
-		}
-
-		chunk := &timestreamwrite.WriteRecordsInput{
-			DatabaseName: aws.String(t.DB),
-			TableName:    aws.String(t.Series),
-			Records:      records[i:end],
+// version returns the version of the records of the point according to the
+// version strategy.
+func (t Timestream) version(p sink.Point, now time.Time) (int64, error) {
+	switch t.Version {
+	case VersionVar:
+		return t.VersionValue, nil
+	case VersionPoint:
+		v, ok := p.Values[t.VersionKey]
+		if !ok || v < 1 {
+			return 0, fmt.Errorf("point at %s has no positive value '%s' to be used as version", p.Timestamp.Format(time.RFC3339Nano), t.VersionKey)
 		}
-
-		if _, err := t.WriteClient.WriteRecords(context.TODO(), chunk); err != nil {
-			return err
+		// NaN passes the check above, +Inf and values of 2^63 and above
+		// do not fit into an int64
+		if math.IsNaN(v) || math.Round(v) >= math.MaxInt64 {
+			return 0, fmt.Errorf("point at %s has value '%s' %v which is out of the range of versions", p.Timestamp.Format(time.RFC3339Nano), t.VersionKey, v)
 		}
+		return int64(math.Round(v)), nil
+	default:
+		return now.Round(time.Millisecond).UnixNano(), nil
 	}
+}
 
-	return nil
+func (t Timestream) valueType(key string) types.MeasureValueType {
+	if vt, ok := t.ValueTypes[key]; ok {
+		return vt
+	}
+	return types.MeasureValueTypeDouble
 }
 
 func (t Timestream) Close() {
 }
+
+// formatValue renders the value as expected by Timestream for the type.
+func formatValue(v float64, vt types.MeasureValueType) string {
+	switch vt {
+	case types.MeasureValueTypeBigint:
+		return strconv.FormatInt(int64(math.Round(v)), 10)
+	case types.MeasureValueTypeBoolean:
+		return strconv.FormatBool(v != 0)
+	case types.MeasureValueTypeVarchar:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
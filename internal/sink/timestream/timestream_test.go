@@ -0,0 +1,204 @@
+package timestream
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"traductio/internal/sink"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+)
+
+func TestRecords(t *testing.T) {
+	points := []sink.Point{
+		{
+			Timestamp: time.Unix(1638316800, 123456789),
+			Tags:      map[string]string{"host": "a"},
+			Values:    map[string]float64{"hits": 3.4, "bytes": 1.5, "up": 1},
+		},
+	}
+	dimensions := []types.Dimension{{Name: aws.String("host"), Value: aws.String("a")}}
+	valueTypes := map[string]types.MeasureValueType{"hits": types.MeasureValueTypeBigint, "up": types.MeasureValueTypeBoolean}
+
+	tests := []struct {
+		name     string
+		ts       Timestream
+		expected []types.Record
+	}{
+		{
+			name: "single",
+			ts:   Timestream{RecordMode: RecordModeSingle, Version: VersionVar, VersionValue: 1, TimeUnit: types.TimeUnitSeconds, unit: time.Second},
+			expected: []types.Record{
+				{Version: 1, Dimensions: dimensions, MeasureName: aws.String("bytes"), MeasureValue: aws.String("1.5"), MeasureValueType: "DOUBLE", Time: aws.String("1638316800"), TimeUnit: "SECONDS"},
+				{Version: 1, Dimensions: dimensions, MeasureName: aws.String("hits"), MeasureValue: aws.String("3.4"), MeasureValueType: "DOUBLE", Time: aws.String("1638316800"), TimeUnit: "SECONDS"},
+				{Version: 1, Dimensions: dimensions, MeasureName: aws.String("up"), MeasureValue: aws.String("1"), MeasureValueType: "DOUBLE", Time: aws.String("1638316800"), TimeUnit: "SECONDS"},
+			},
+		},
+		{
+			name: "multi",
+			ts:   Timestream{RecordMode: RecordModeMulti, Version: VersionVar, VersionValue: 1, MeasureName: "traffic", TimeUnit: types.TimeUnitMilliseconds, unit: time.Millisecond, ValueTypes: valueTypes},
+			expected: []types.Record{
+				{
+					Version:     1,
+					Dimensions:  dimensions,
+					MeasureName: aws.String("traffic"),
+					MeasureValues: []types.MeasureValue{
+						{Name: aws.String("bytes"), Value: aws.String("1.5"), Type: "DOUBLE"},
+						{Name: aws.String("hits"), Value: aws.String("3"), Type: "BIGINT"},
+						{Name: aws.String("up"), Value: aws.String("true"), Type: "BOOLEAN"},
+					},
+					MeasureValueType: "MULTI",
+					Time:             aws.String("1638316800123"),
+					TimeUnit:         "MILLISECONDS",
+				},
+			},
+		},
+		{
+			name: "single_version_point",
+			ts:   Timestream{RecordMode: RecordModeSingle, Version: VersionPoint, VersionKey: "up", TimeUnit: types.TimeUnitSeconds, unit: time.Second},
+			expected: []types.Record{
+				{Version: 1, Dimensions: dimensions, MeasureName: aws.String("bytes"), MeasureValue: aws.String("1.5"), MeasureValueType: "DOUBLE", Time: aws.String("1638316800"), TimeUnit: "SECONDS"},
+				{Version: 1, Dimensions: dimensions, MeasureName: aws.String("hits"), MeasureValue: aws.String("3.4"), MeasureValueType: "DOUBLE", Time: aws.String("1638316800"), TimeUnit: "SECONDS"},
+			},
+		},
+		{
+			name: "multi_version_point",
+			ts:   Timestream{RecordMode: RecordModeMulti, Version: VersionPoint, VersionKey: "hits", MeasureName: "traffic", TimeUnit: types.TimeUnitSeconds, unit: time.Second, ValueTypes: valueTypes},
+			expected: []types.Record{
+				{
+					Version:     3,
+					Dimensions:  dimensions,
+					MeasureName: aws.String("traffic"),
+					MeasureValues: []types.MeasureValue{
+						{Name: aws.String("bytes"), Value: aws.String("1.5"), Type: "DOUBLE"},
+						{Name: aws.String("up"), Value: aws.String("true"), Type: "BOOLEAN"},
+					},
+					MeasureValueType: "MULTI",
+					Time:             aws.String("1638316800"),
+					TimeUnit:         "SECONDS",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := test.ts.records(points, time.Now())
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("records are not as expected:\n%+v\n%+v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	now := time.Unix(1638316800, 123456789)
+	p := sink.Point{Timestamp: now, Values: map[string]float64{"hits": 3, "count": 1200.4, "nan": math.NaN(), "inf": math.Inf(1), "huge": 1e19, "max": 9.2e18}}
+
+	tests := []struct {
+		name        string
+		ts          Timestream
+		errExpected bool
+		expected    int64
+	}{
+		{name: "wallclock", ts: Timestream{Version: VersionWallclock}, expected: 1638316800123000000},
+		{name: "var", ts: Timestream{Version: VersionVar, VersionValue: 1638230400000}, expected: 1638230400000},
+		{name: "point", ts: Timestream{Version: VersionPoint, VersionKey: "count"}, expected: 1200},
+		{name: "point_missing", ts: Timestream{Version: VersionPoint, VersionKey: "docs"}, errExpected: true},
+		{name: "point_nan", ts: Timestream{Version: VersionPoint, VersionKey: "nan"}, errExpected: true},
+		{name: "point_inf", ts: Timestream{Version: VersionPoint, VersionKey: "inf"}, errExpected: true},
+		{name: "point_out_of_range", ts: Timestream{Version: VersionPoint, VersionKey: "huge"}, errExpected: true},
+		{name: "point_in_range", ts: Timestream{Version: VersionPoint, VersionKey: "max"}, expected: 9200000000000000000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.ts.version(p, now)
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if got != test.expected {
+				t.Errorf("version %d was expected, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+// writer is a stand-in for Timestream which fails the first chunk and
+// rejects two records of the second chunk.
+type writer struct {
+	chunks int
+}
+
+func (w *writer) WriteRecords(ctx context.Context, params *timestreamwrite.WriteRecordsInput, optFns ...func(*timestreamwrite.Options)) (*timestreamwrite.WriteRecordsOutput, error) {
+	w.chunks++
+	switch w.chunks {
+	case 1:
+		return nil, fmt.Errorf("throttled")
+	case 2:
+		return nil, &types.RejectedRecordsException{
+			Message: aws.String("One or more records have been rejected"),
+			RejectedRecords: []types.RejectedRecord{
+				{RecordIndex: 3, Reason: aws.String("The record timestamp is outside the time range of the data ingestion window.")},
+				{RecordIndex: 7, Reason: aws.String("A record with the same version already exists."), ExistingVersion: 42},
+			},
+		}
+	}
+	return &timestreamwrite.WriteRecordsOutput{}, nil
+}
+
+func TestWriteRejections(t *testing.T) {
+	points := []sink.Point{}
+	for i := 0; i < 250; i++ {
+		points = append(points, sink.Point{
+			Timestamp: time.Unix(int64(i), 0),
+			Tags:      map[string]string{"host": fmt.Sprintf("h%d", i)},
+			Values:    map[string]float64{"hits": float64(i)},
+		})
+	}
+
+	tests := []struct {
+		name       string
+		ignore     bool
+		rejections int
+	}{
+		{name: "report_all", ignore: false, rejections: 2},
+		{name: "ignore_version_conflicts", ignore: true, rejections: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &writer{}
+			ts := Timestream{WriteClient: w, RecordMode: RecordModeSingle, unit: time.Second, IgnoreVersionConflicts: test.ignore}
+
+			err := ts.Write(points)
+			if w.chunks != 3 {
+				t.Errorf("all 3 chunks should be written, %d were written", w.chunks)
+			}
+			report, ok := err.(WriteError)
+			if !ok {
+				t.Fatalf("WriteError was expected, error was '%v'", err)
+			}
+			if report.Chunks != 3 || len(report.Failed) != 1 || report.Failed[1] == nil {
+				t.Errorf("failed chunks are not as expected: %v", report.Failed)
+			}
+			if len(report.Rejections) != test.rejections {
+				t.Fatalf("%d rejections were expected, got %d", test.rejections, len(report.Rejections))
+			}
+			r := report.Rejections[0]
+			if r.Chunk != 2 || r.Record != 3 || r.Point.Tags["host"] != "h103" || r.Measure != "hits" {
+				t.Errorf("rejection is not mapped to its point: %s", r)
+			}
+		})
+	}
+}
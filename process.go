@@ -1,10 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"traductio/internal/sink"
@@ -12,114 +12,237 @@ import (
 	"github.com/itchyny/gojq"
 )
 
+// compiledIterator holds the queries of an Iterator compiled once, so they
+// can be run against any number of decoded elements.
+type compiledIterator struct {
+	Iterator
+	selector *gojq.Code
+	time     *gojq.Code
+	tags     map[string]*gojq.Code
+	values   map[string]*gojq.Code
+	next     *compiledIterator
+}
+
+// Compile compiles all selectors of the iterator and its nested iterators.
+// Every invalid selector is reported with its location in the configuration.
+func (i Iterator) Compile() (*compiledIterator, []error) {
+	return i.compile("process.iterator")
+}
+
+func (i Iterator) compile(path string) (*compiledIterator, []error) {
+	c := &compiledIterator{
+		Iterator: i,
+		tags:     map[string]*gojq.Code{},
+		values:   map[string]*gojq.Code{},
+	}
+	errs := []error{}
+
+	var err error
+	if c.selector, err = compileQuery(path+".selector", i.Selector); err != nil {
+		errs = append(errs, err)
+	}
+	if i.Time.Selector != "" {
+		if c.time, err = compileQuery(path+".time.selector", i.Time.Selector); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range sortedKeys(i.Tags) {
+		if c.tags[key], err = compileQuery(path+".tags."+key, i.Tags[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range sortedKeys(i.Values) {
+		if c.values[key], err = compileQuery(path+".values."+key, i.Values[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if i.Iterator != nil {
+		var nested []error
+		c.next, nested = i.Iterator.compile(path + ".iterator")
+		errs = append(errs, nested...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+// Process decodes the JSON document j and extracts the points described by
+// the iterator i from it. Use Iterator.Compile and compiledIterator.Process
+// to process more than one document with the same iterator.
 func Process(j []byte, i Iterator, inherited sink.Point, test bool) ([]sink.Point, bool, string, error) {
-	results := []sink.Point{}
+	c, errs := i.Compile()
+	if len(errs) > 0 {
+		return []sink.Point{}, false, "", errs[0]
+	}
 
-	selected, err := queryList(j, i.Selector)
-	if err != nil {
-		return results, false, "", err
+	var doc interface{}
+	if err := json.Unmarshal(j, &doc); err != nil {
+		return []sink.Point{}, false, "", err
 	}
+	return c.Process(doc, inherited, test)
+}
 
-	var elements []interface{}
+// Process extracts the points from the decoded JSON document doc. In test
+// mode it stops at the first element of the innermost iterator and returns
+// it as indented JSON fragment.
+func (c *compiledIterator) Process(doc interface{}, inherited sink.Point, test bool) ([]sink.Point, bool, string, error) {
+	results := []sink.Point{}
 
-	err = json.Unmarshal(selected, &elements)
+	elements, err := queryList(doc, c.selector)
 	if err != nil {
 		return results, false, "", err
 	}
 
 	for _, element := range elements {
-		point := inherited.Copy()
-		elem, err := json.MarshalIndent(element, "", "  ")
-
+		processed, stop, jsonFragment, err := c.element(element, inherited, test)
+		results = append(results, processed...)
 		if err != nil {
 			return results, false, "", err
 		}
-
-		if i.Time.Selector != "" {
-			if i.Time.Format == "unixMilliTimestamp" {
-				out, err := queryValue(elem, i.Time.Selector)
-				if err != nil {
-					return results, false, "", err
-				}
-				point.Timestamp = time.Unix(int64(out)/1000, 0)
-			} else if i.Time.Format == "unixTimestamp" {
-				out, err := queryValue(elem, i.Time.Selector)
-				if err != nil {
-					return results, false, "", err
-				}
-				point.Timestamp = time.Unix(int64(out), 0)
-			} else {
-				out, err := queryBytes(elem, i.Time.Selector)
-				if err != nil {
-					return results, false, "", err
-				}
-				point.Timestamp, err = time.Parse(i.Time.Format, string(out))
-				if err != nil {
-					return results, false, "", err
-				}
-			}
+		if stop {
+			return results, stop, jsonFragment, nil
 		}
+	}
+
+	return results, false, "", nil
+}
 
-		for key, selector := range i.Values {
-			out, err := queryValue(elem, selector)
+// element extracts the points from a single element selected by the
+// iterator.
+func (c *compiledIterator) element(element interface{}, inherited sink.Point, test bool) ([]sink.Point, bool, string, error) {
+	results := []sink.Point{}
+	point := inherited.Copy()
+	var err error
+
+	if c.time != nil {
+		if c.Time.Format == "unixMilliTimestamp" {
+			out, err := queryValue(element, c.time)
+			if err != nil {
+				return results, false, "", err
+			}
+			point.Timestamp = time.Unix(int64(out)/1000, 0)
+		} else if c.Time.Format == "unixTimestamp" {
+			out, err := queryValue(element, c.time)
+			if err != nil {
+				return results, false, "", err
+			}
+			point.Timestamp = time.Unix(int64(out), 0)
+		} else {
+			out, err := queryBytes(element, c.time)
+			if err != nil {
+				return results, false, "", err
+			}
+			point.Timestamp, err = time.Parse(c.Time.Format, string(out))
 			if err != nil {
 				return results, false, "", err
 			}
-			point.Values[key] = out
 		}
+	}
 
-		for key, value := range i.FixedValues {
-			point.Values[key] = value
+	for key, query := range c.values {
+		out, err := queryValue(element, query)
+		if err != nil {
+			return results, false, "", err
 		}
+		point.Values[key] = out
+	}
 
-		for key, selector := range i.Tags {
-			out, err := queryBytes(elem, selector)
-			if err != nil {
-				return results, false, "", err
-			}
-			trimmed := strings.Trim(string(out), "\"\\")
-			point.Tags[key] = trimmed
+	for key, value := range c.FixedValues {
+		point.Values[key], err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return results, false, "", fmt.Errorf("fixed value '%s' is not a number: %s", key, value)
 		}
+	}
 
-		for key, value := range i.FixedTags {
-			point.Tags[key] = value
+	for key, query := range c.tags {
+		out, err := queryBytes(element, query)
+		if err != nil {
+			return results, false, "", err
 		}
+		trimmed := strings.Trim(string(out), "\"\\")
+		point.Tags[key] = trimmed
+	}
 
-		if i.Iterator != nil {
-			processed, stop, jsonFragment, err := Process(elem, *i.Iterator, point, test)
+	for key, value := range c.FixedTags {
+		point.Tags[key] = value
+	}
+
+	if c.next != nil {
+		processed, stop, jsonFragment, err := c.next.Process(element, point, test)
+		if err != nil {
+			return results, false, "", err
+		}
+		results = append(results, processed...)
+		if stop {
+			return results, stop, jsonFragment, nil
+		}
+	} else {
+		if !point.IsEmpty() {
+			results = append(results, point)
+		}
+		if test {
+			elem, err := json.MarshalIndent(element, "", "  ")
 			if err != nil {
 				return results, false, "", err
 			}
-			results = append(results, processed...)
-			if stop {
-				return results, stop, jsonFragment, nil
-			}
-		} else {
-			if !point.IsEmpty() {
-				results = append(results, point)
-			}
-			if test {
-				return results, true, string(elem), nil
-			}
+			return results, true, string(elem), nil
 		}
 	}
-
 	return results, false, "", nil
 }
 
-func queryList(j []byte, q string) ([]byte, error) {
-	//j = bytes.ReplaceAll(j, []byte("buckets\":null"), []byte("buckets\":[]"))
-	var input map[string]interface{}
-	err := json.Unmarshal(j, &input)
-	if err != nil {
-		return nil, err
+// QueryError describes a selector which could not be compiled.
+type QueryError struct {
+	// Path is the location of the selector in the configuration, e.g.
+	// 'process.iterator.values.count'.
+	Path  string
+	Query string
+	// Offset is the position within the query, counting from 1, of the
+	// first character of the token the error was detected at. For an
+	// unexpected end of the query it is the position after its last
+	// character, -1 if unknown.
+	Offset int
+	Err    error
+}
+
+func (e QueryError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("invalid selector '%s' at %s: %s", e.Query, e.Path, e.Err.Error())
 	}
+	return fmt.Sprintf("invalid selector '%s' at %s: %s at position %d", e.Query, e.Path, e.Err.Error(), e.Offset)
+}
 
+// compileQuery parses and compiles the jq query q found at path.
+func compileQuery(path, q string) (*gojq.Code, error) {
 	query, err := gojq.Parse(q)
 	if err != nil {
-		log.Fatalln(err)
+		offset := -1
+		if t, ok := err.(interface{ Token() (string, int) }); ok {
+			_, offset = t.Token()
+		}
+		return nil, QueryError{Path: path, Query: q, Offset: offset, Err: err}
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, QueryError{Path: path, Query: q, Offset: -1, Err: err}
+	}
+	return code, nil
+}
+
+// sortedKeys returns the keys of the map m in alphabetical order.
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
+// queryList returns all outputs of the query.
+func queryList(input interface{}, query *gojq.Code) ([]interface{}, error) {
 	var out []interface{}
 	iter := query.Run(input)
 	for {
@@ -128,26 +251,15 @@ func queryList(j []byte, q string) ([]byte, error) {
 			break
 		}
 		if err, ok := v.(error); ok {
-			return []byte{}, err
+			return nil, err
 		}
 		out = append(out, v)
 	}
-	return json.Marshal(out)
+	return out, nil
 }
 
-func queryBytes(j []byte, q string) ([]byte, error) {
-	//j = bytes.ReplaceAll(j, []byte("buckets\":null"), []byte("buckets\":[]"))
-	var input map[string]interface{}
-	err := json.Unmarshal(j, &input)
-	if err != nil {
-		return nil, err
-	}
-
-	query, err := gojq.Parse(q)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
+// queryBytes returns the last output of the query as JSON.
+func queryBytes(input interface{}, query *gojq.Code) ([]byte, error) {
 	var out interface{}
 	iter := query.Run(input)
 	for {
@@ -163,20 +275,8 @@ func queryBytes(j []byte, q string) ([]byte, error) {
 	return json.Marshal(out)
 }
 
-func queryValue(j []byte, q string) (float64, error) {
-	j = bytes.ReplaceAll(j, []byte("buckets\":null"), []byte("buckets\":[]"))
-
-	var input map[string]interface{}
-	err := json.Unmarshal(j, &input)
-	if err != nil {
-		return 0.0, err
-	}
-
-	query, err := gojq.Parse(q)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
+// queryValue returns the last output of the query which has to be a number.
+func queryValue(input interface{}, query *gojq.Code) (float64, error) {
 	var out float64
 	iter := query.Run(input)
 	for {
@@ -190,7 +290,6 @@ func queryValue(j []byte, q string) (float64, error) {
 		if out, ok = v.(float64); !ok {
 			return out, fmt.Errorf("could not read value as float64")
 		}
-
 	}
 	return out, nil
 }
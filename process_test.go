@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -203,3 +205,152 @@ func TestProcess(t *testing.T) {
 		})
 	}
 }
+
+// benchIterator is the deepest iterator of the process test sets.
+var benchIterator = Iterator{
+	Selector: ".by_time[]",
+	Time: TimeSet{
+		Selector: ".time",
+		Format:   "unixMilliTimestamp",
+	},
+	Iterator: &Iterator{
+		Selector: ".groups[]",
+		Tags: map[string]string{
+			"name": ".name",
+		},
+		Values: map[string]string{
+			"count":  ".values.count",
+			"volume": ".values.volume",
+		},
+	},
+}
+
+// largeResponse returns a synthetic response shaped like the test fixture
+// with the number of time buckets and groups passed.
+func largeResponse(buckets, groups int) []byte {
+	byTime := []interface{}{}
+	for b := 0; b < buckets; b++ {
+		g := []interface{}{}
+		for i := 0; i < groups; i++ {
+			g = append(g, map[string]interface{}{
+				"name":   fmt.Sprintf("group-%d", i),
+				"values": map[string]interface{}{"count": i, "volume": i * b},
+				"extra":  map[string]interface{}{"padding": "some text which is not selected at all", "list": []int{1, 2, 3}},
+			})
+		}
+		byTime = append(byTime, map[string]interface{}{"time": 1642892400000 + b*60000, "groups": g})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"by_time": byTime})
+	return data
+}
+
+func BenchmarkProcess(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		if _, _, _, err := Process(jsn, benchIterator, sink.Point{}, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessLarge(b *testing.B) {
+	data := largeResponse(100, 100)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, _, _, err := Process(data, benchIterator, sink.Point{}, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessLargeCompiled(b *testing.B) {
+	var doc interface{}
+	if err := json.Unmarshal(largeResponse(100, 100), &doc); err != nil {
+		b.Fatal(err)
+	}
+	i, errs := benchIterator.Compile()
+	if len(errs) > 0 {
+		b.Fatal(errs)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, _, _, err := i.Process(doc, sink.Point{}, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProcessRoots(t *testing.T) {
+	var rootTestSets = []struct {
+		name        string
+		data        string
+		i           Iterator
+		errExpected bool
+		points      []sink.Point
+	}{
+		{
+			name: "array",
+			data: `[{"time": 1642892400, "name": "foo", "count": 1}, {"time": 1642892400, "name": "bar", "count": 2}]`,
+			i: Iterator{
+				Selector: ".[]",
+				Time:     TimeSet{Selector: ".time", Format: "unixTimestamp"},
+				Tags:     map[string]string{"name": ".name"},
+				Values:   map[string]string{"count": ".count"},
+			},
+			points: []sink.Point{
+				{Timestamp: refTime, Tags: map[string]string{"name": "foo"}, Values: map[string]float64{"count": 1}},
+				{Timestamp: refTime, Tags: map[string]string{"name": "bar"}, Values: map[string]float64{"count": 2}},
+			},
+		},
+		{
+			name: "nested_arrays",
+			data: `[[1642892400, [3, 4]]]`,
+			i: Iterator{
+				Selector: ".[]",
+				Time:     TimeSet{Selector: ".[0]", Format: "unixTimestamp"},
+				Iterator: &Iterator{
+					Selector: ".[1][]",
+					Values:   map[string]string{"count": "."},
+				},
+			},
+			points: []sink.Point{
+				{Timestamp: refTime, Tags: map[string]string{}, Values: map[string]float64{"count": 3}},
+				{Timestamp: refTime, Tags: map[string]string{}, Values: map[string]float64{"count": 4}},
+			},
+		},
+		{
+			name: "scalar",
+			data: `1642892400`,
+			i: Iterator{
+				Selector:    ".",
+				Time:        TimeSet{Selector: ".", Format: "unixTimestamp"},
+				FixedValues: map[string]string{"up": "1"},
+			},
+			points: []sink.Point{
+				{Timestamp: refTime, Tags: map[string]string{}, Values: map[string]float64{"up": 1}},
+			},
+		},
+		{
+			name:        "scalar_iterated",
+			data:        `"text"`,
+			i:           Iterator{Selector: ".[]"},
+			errExpected: true,
+			points:      []sink.Point{},
+		},
+	}
+
+	for _, test := range rootTestSets {
+		t.Run(test.name, func(t *testing.T) {
+			points, _, _, err := Process([]byte(test.data), test.i, sink.Point{}, false)
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if !reflect.DeepEqual(points, test.points) {
+				t.Log(points)
+				t.Log(test.points)
+				t.Errorf("points are not as expected")
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"traductio/internal/sink"
+)
+
+const defaultBatchSize = 10000
+
+// StreamPath returns the keys of the objects leading to the elements the
+// selector of the iterator iterates over, e.g. ["hits", "hits"] for the
+// selector '.hits.hits[]'. Only selectors of that form can be streamed.
+func (i Iterator) StreamPath() ([]string, error) {
+	path, err := streamPath(i.Selector)
+	if err != nil {
+		return nil, QueryError{Path: "process.iterator.selector", Query: i.Selector, Offset: -1, Err: err}
+	}
+	return path, nil
+}
+
+func streamPath(selector string) ([]string, error) {
+	invalid := fmt.Errorf("selector cannot be streamed, only paths of object keys followed by '[]' such as '.hits.hits[]' can")
+
+	q := strings.TrimSpace(selector)
+	if !strings.HasPrefix(q, ".") || !strings.HasSuffix(q, "[]") {
+		return nil, invalid
+	}
+	q = strings.TrimSuffix(q, "[]")
+	if q == "." {
+		return []string{}, nil
+	}
+
+	path := []string{}
+	for q != "" {
+		// keys are either given as '.key', '."key"' or '["key"]'
+		bracket := false
+		if strings.HasPrefix(q, ".[") {
+			bracket = true
+			q = q[2:]
+		} else if strings.HasPrefix(q, "[") {
+			bracket = true
+			q = q[1:]
+		} else if strings.HasPrefix(q, ".") {
+			q = q[1:]
+		} else {
+			return nil, invalid
+		}
+
+		var key string
+		if strings.HasPrefix(q, "\"") {
+			end := 1
+			for ; end < len(q) && q[end] != '"'; end++ {
+				if q[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(q) {
+				return nil, invalid
+			}
+			var err error
+			if key, err = strconv.Unquote(q[:end+1]); err != nil {
+				return nil, invalid
+			}
+			q = q[end+1:]
+		} else if !bracket {
+			end := 0
+			for ; end < len(q); end++ {
+				c := q[end]
+				if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (end > 0 && c >= '0' && c <= '9')) {
+					break
+				}
+			}
+			if end == 0 {
+				return nil, invalid
+			}
+			key, q = q[:end], q[end:]
+		} else {
+			return nil, invalid
+		}
+
+		if bracket {
+			if !strings.HasPrefix(q, "]") {
+				return nil, invalid
+			}
+			q = q[1:]
+		}
+		path = append(path, key)
+	}
+	return path, nil
+}
+
+// streamer walks a JSON document token by token down the path to the
+// elements to be iterated over. Everything else is decoded into the skeleton
+// of the document.
+type streamer struct {
+	dec      *json.Decoder
+	path     []string
+	skeleton interface{}
+	found    bool
+	head     func(interface{}) error
+	element  func(interface{}) error
+}
+
+// streamElements reads the JSON document from r and calls element for each
+// value of the array or object found at path without decoding the document
+// as a whole. Before the first element head is called with the skeleton of
+// the document read so far, e.g. everything preceding the elements.
+func streamElements(r io.Reader, path []string, head, element func(interface{}) error) error {
+	s := &streamer{
+		dec:     json.NewDecoder(r),
+		path:    path,
+		head:    head,
+		element: element,
+	}
+	if err := s.walk(0, func(v interface{}) { s.skeleton = v }); err != nil {
+		return err
+	}
+	if !s.found {
+		return fmt.Errorf("cannot iterate over null at '%s'", s.location(len(path)))
+	}
+	return nil
+}
+
+// walk reads the value at the depth given of the path, set stores the
+// skeleton of the value in its parent.
+func (s *streamer) walk(depth int, set func(interface{})) error {
+	t, err := s.dec.Token()
+	if err != nil {
+		return s.error(err)
+	}
+
+	if depth == len(s.path) {
+		switch t {
+		case json.Delim('['):
+			set([]interface{}{})
+			return s.iterate(false)
+		case json.Delim('{'):
+			set(map[string]interface{}{})
+			return s.iterate(true)
+		}
+		return fmt.Errorf("cannot iterate over %s at '%s'", describe(t), s.location(depth))
+	}
+
+	if t != json.Delim('{') {
+		return fmt.Errorf("cannot index %s with '%s' at '%s'", describe(t), s.path[depth], s.location(depth))
+	}
+	m := map[string]interface{}{}
+	set(m)
+	for s.dec.More() {
+		t, err := s.dec.Token()
+		if err != nil {
+			return s.error(err)
+		}
+		key, _ := t.(string)
+		if key == s.path[depth] && !s.found {
+			err = s.walk(depth+1, func(v interface{}) { m[key] = v })
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var v interface{}
+		if err := s.dec.Decode(&v); err != nil {
+			return s.error(err)
+		}
+		m[key] = v
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return s.error(err)
+	}
+	return nil
+}
+
+// iterate passes the values of the array or object just opened to element
+// one by one.
+func (s *streamer) iterate(object bool) error {
+	s.found = true
+	if err := s.head(s.skeleton); err != nil {
+		return err
+	}
+	for s.dec.More() {
+		if object {
+			if _, err := s.dec.Token(); err != nil {
+				return s.error(err)
+			}
+		}
+		var v interface{}
+		if err := s.dec.Decode(&v); err != nil {
+			return s.error(err)
+		}
+		if err := s.element(v); err != nil {
+			return err
+		}
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return s.error(err)
+	}
+	return nil
+}
+
+// location returns the path up to the depth given as jq selector.
+func (s *streamer) location(depth int) string {
+	if depth == 0 {
+		return "."
+	}
+	return "." + strings.Join(s.path[:depth], ".")
+}
+
+func (s *streamer) error(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("error while reading JSON at byte %d: %s", s.dec.InputOffset(), err.Error())
+}
+
+// describe returns the type of the JSON value starting with the token t.
+func describe(t json.Token) string {
+	switch v := t.(type) {
+	case json.Delim:
+		if v == '[' {
+			return "array"
+		}
+		return "object"
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	}
+	return "unknown value"
+}
+
+// Stream processes the JSON document read from r without decoding it as a
+// whole. The selector of the iterator is evaluated incrementally, it has to
+// be a path as described by Iterator.StreamPath. The skeleton of the
+// document preceding the elements is passed to head, e.g. to validate it.
+// The points extracted are passed to emit in batches of up to size points.
+func (c *compiledIterator) Stream(r io.Reader, head func(interface{}) error, emit func([]sink.Point) error, size int) error {
+	path, err := c.StreamPath()
+	if err != nil {
+		return err
+	}
+	if size < 1 {
+		size = defaultBatchSize
+	}
+
+	batch := []sink.Point{}
+	err = streamElements(r, path, head, func(element interface{}) error {
+		points, _, _, err := c.element(element, sink.Point{}, false)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, points...)
+		for len(batch) >= size {
+			if err := emit(batch[:size]); err != nil {
+				return err
+			}
+			batch = append([]sink.Point{}, batch[size:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return emit(batch)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"traductio/internal/sink"
+)
+
+func TestStreamPath(t *testing.T) {
+	var streamPathTestSets = []struct {
+		selector    string
+		path        []string
+		errExpected bool
+	}{
+		{".[]", []string{}, false},
+		{".hits.hits[]", []string{"hits", "hits"}, false},
+		{".aggregations.\"over time\".buckets[]", []string{"aggregations", "over time", "buckets"}, false},
+		{".aggregations[\"over time\"][]", []string{"aggregations", "over time"}, false},
+		{".[\"a.b\"].c_1[]", []string{"a.b", "c_1"}, false},
+		{" .items[] ", []string{"items"}, false},
+		{".items", nil, true},
+		{".items[0][]", nil, true},
+		{".items[] | .values[]", nil, true},
+		{".items[].values[]", nil, true},
+		{".1a[]", nil, true},
+		{"items[]", nil, true},
+		{".\"open[]", nil, true},
+	}
+
+	for _, test := range streamPathTestSets {
+		t.Run(test.selector, func(t *testing.T) {
+			path, err := Iterator{Selector: test.selector}.StreamPath()
+			if err == nil && test.errExpected {
+				t.Errorf("error was expected, error was <nil>")
+			} else if err != nil && !test.errExpected {
+				t.Errorf("no error was expected, error was '%s'", err)
+			}
+			if !reflect.DeepEqual(path, test.path) {
+				t.Errorf("path is not as expected: %q", path)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	for _, test := range processTestSets {
+		if _, err := test.i.StreamPath(); err != nil || test.errExpected {
+			continue
+		}
+		t.Run(test.name, func(t *testing.T) {
+			c, errs := test.i.Compile()
+			if len(errs) > 0 {
+				t.Fatalf("no error was expected, errors were %v", errs)
+			}
+
+			var head interface{}
+			points := []sink.Point{}
+			err := c.Stream(bytes.NewReader(jsn), func(doc interface{}) error {
+				head = doc
+				return nil
+			}, func(batch []sink.Point) error {
+				if len(batch) > 2 {
+					t.Errorf("batch of %d points exceeds batch size", len(batch))
+				}
+				points = append(points, batch...)
+				return nil
+			}, 2)
+			if err != nil {
+				t.Fatalf("no error was expected, error was '%s'", err)
+			}
+
+			expected := map[string]interface{}{"by_time": []interface{}{}}
+			if !reflect.DeepEqual(head, expected) {
+				t.Errorf("head is not as expected: %v", head)
+			}
+			if !reflect.DeepEqual(points, test.points) {
+				t.Log(points)
+				t.Log(test.points)
+				t.Errorf("points are not as expected")
+			}
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	var streamErrorTestSets = []struct {
+		name     string
+		selector string
+		data     string
+		err      string
+	}{
+		{"missing", ".items[]", `{"other":[1]}`, "cannot iterate over null at '.items'"},
+		{"not_iterable", ".items[]", `{"items":1}`, "cannot iterate over number at '.items'"},
+		{"not_indexable", ".items.values[]", `{"items":[]}`, "cannot index array with 'values' at '.items'"},
+		{"malformed", ".items[]", `{"items":[{"a":1},{"a":]}`, "error while reading JSON at byte"},
+		{"truncated", ".items[]", `{"items":[{"a":1}`, "unexpected end of JSON input"},
+	}
+
+	for _, test := range streamErrorTestSets {
+		t.Run(test.name, func(t *testing.T) {
+			c, errs := Iterator{Selector: test.selector, Values: map[string]string{"a": ".a"}}.Compile()
+			if len(errs) > 0 {
+				t.Fatalf("no error was expected, errors were %v", errs)
+			}
+			noop := func(interface{}) error { return nil }
+			err := c.Stream(strings.NewReader(test.data), noop, func([]sink.Point) error { return nil }, 10)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error containing '%s' was expected, error was '%v'", test.err, err)
+			}
+		})
+	}
+}
+
+func BenchmarkStreamLarge(b *testing.B) {
+	data := largeResponse(100, 100)
+	i, errs := benchIterator.Compile()
+	if len(errs) > 0 {
+		b.Fatal(errs)
+	}
+	noop := func(interface{}) error { return nil }
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := i.Stream(bytes.NewReader(data), noop, func([]sink.Point) error { return nil }, 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStreamRoots(t *testing.T) {
+	i := Iterator{
+		Selector: ".[]",
+		Time:     TimeSet{Selector: ".time", Format: "unixTimestamp"},
+		Values:   map[string]string{"count": ".count"},
+	}
+	c, errs := i.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("no error was expected, errors were %v", errs)
+	}
+	expected := []sink.Point{
+		{Timestamp: refTime, Tags: map[string]string{}, Values: map[string]float64{"count": 1}},
+		{Timestamp: refTime, Tags: map[string]string{}, Values: map[string]float64{"count": 2}},
+	}
+
+	for _, data := range []string{
+		`[{"time": 1642892400, "count": 1}, {"time": 1642892400, "count": 2}]`,
+		`{"a": {"time": 1642892400, "count": 1}, "b": {"time": 1642892400, "count": 2}}`,
+	} {
+		points := []sink.Point{}
+		noop := func(interface{}) error { return nil }
+		err := c.Stream(strings.NewReader(data), noop, func(batch []sink.Point) error {
+			points = append(points, batch...)
+			return nil
+		}, 10)
+		if err != nil {
+			t.Errorf("no error was expected for %s, error was '%s'", data, err)
+		}
+		if !reflect.DeepEqual(points, expected) {
+			t.Errorf("points are not as expected for %s: %v", data, points)
+		}
+	}
+
+	noop := func(interface{}) error { return nil }
+	err := c.Stream(strings.NewReader(`42`), noop, func([]sink.Point) error { return nil }, 10)
+	if err == nil || !strings.Contains(err.Error(), "cannot iterate over number at '.'") {
+		t.Errorf("error was expected for a scalar, error was '%v'", err)
+	}
+}